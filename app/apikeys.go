@@ -0,0 +1,114 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+	"github.com/YuChaoGithub/meme-linebot/config"
+)
+
+const apiKeysUsage = "Usage: meme-linebot apikey [create <name> <scope>... | list | revoke <name> | signing-secret <name>]\n" +
+	"Scopes: " + scopeMemesRead + " " + scopeMemesWrite + " " + scopeMemesDelete + " " + scopeStatsRead + " " + scopeAuditRead
+
+// APIKeys runs the apikey subcommand against the configured database and writes the result to
+// out. A created key is printed once; only its hash is stored. The signing secret of a key is
+// derived from its hash with ADMIN_KEY_PEPPER, so it can be printed again by signing-secret.
+func APIKeys(config *config.Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(apiKeysUsage)
+	}
+
+	db, err := connectDB(config.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return runAPIKeys(&models.MemeModel{DB: db}, config.Admin, args, out)
+}
+
+// runAPIKeys runs the apikey subcommand against the storage.
+func runAPIKeys(store models.APIKeyStore, admin config.AdminConfig, args []string, out io.Writer) error {
+	switch {
+	case args[0] == "create" && len(args) >= 3:
+		name, scopes := args[1], args[2:]
+		if name == legacyKeyName || len(name) > 64 {
+			return fmt.Errorf("invalid key name %q", name)
+		}
+		for _, scope := range scopes {
+			if !validScope(scope) {
+				return fmt.Errorf("invalid scope %q\n%s", scope, apiKeysUsage)
+			}
+		}
+
+		key, err := newAPIKey()
+		if err != nil {
+			return err
+		}
+
+		err = store.CreateAPIKey(models.APIKey{
+			Name:      name,
+			Hash:      hashAPIKey(key),
+			Scopes:    scopes,
+			CreatedAt: time.Now(),
+		})
+		if errors.Is(err, models.ErrDuplicateKey) {
+			return fmt.Errorf("key %q already exists", name)
+		} else if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "Created key %q. Store it now, it will not be shown again:\nkey: %s\n", name, key)
+		if admin.KeyPepper != "" {
+			fmt.Fprintf(out, "signing secret: %s\n", signingSecret(admin.KeyPepper, hashAPIKey(key)))
+		}
+	case args[0] == "list" && len(args) == 1:
+		keys, err := store.ListAPIKeys()
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			status := "active"
+			if key.Revoked() {
+				status = "revoked at " + key.RevokedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%-24s %-48s created at %v, %s\n",
+				key.Name, strings.Join(key.Scopes, " "), key.CreatedAt.Format("2006-01-02 15:04:05"), status)
+		}
+	case args[0] == "revoke" && len(args) == 2:
+		err := store.RevokeAPIKey(args[1], time.Now())
+		if errors.Is(err, models.ErrNoRecord) {
+			return fmt.Errorf("key %q does not exist", args[1])
+		} else if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "Revoked key %q.\n", args[1])
+	case args[0] == "signing-secret" && len(args) == 2:
+		if admin.KeyPepper == "" {
+			return errors.New("signed requests need ADMIN_KEY_PEPPER")
+		}
+
+		keyHash := hashAPIKey(admin.Secret)
+		if args[1] != legacyKeyName || admin.Secret == "" {
+			key, err := store.GetAPIKey(args[1])
+			if errors.Is(err, models.ErrNoRecord) {
+				return fmt.Errorf("key %q does not exist", args[1])
+			} else if err != nil {
+				return err
+			}
+			keyHash = key.Hash
+		}
+
+		fmt.Fprintln(out, signingSecret(admin.KeyPepper, keyHash))
+	default:
+		return errors.New(apiKeysUsage)
+	}
+
+	return nil
+}
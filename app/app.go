@@ -1,9 +1,15 @@
 package app
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/YuChaoGithub/meme-linebot/app/models"
@@ -14,92 +20,275 @@ import (
 )
 
 const (
-	reconnectionInterval = 5
+	memoryDialect      = "memory"
+	databaseDedupStore = "database"
 )
 
 // App contains all the required models for the application.
 type App struct {
-	adminSecret   string
-	memeModel     *models.MemeModel
-	bot           *linebot.Client
-	pageTemplates templateCache
+	adminSecret      string
+	keyPepper        string // Derives the signing secrets of the API keys.
+	requireSignature bool
+	signatureMaxAge  time.Duration
+	signatures       processedEvents // The signatures of the signed admin requests.
+	apiKeys          models.APIKeyStore
+	audits           models.AuditStore
+	trustProxy       bool          // Whether X-Forwarded-For gives the client IP address.
+	trashRetention   time.Duration // How long a deleted meme is kept before it can be purged.
+	statsHashSalt    string
+	greetingMeme     string
+	farewellMeme     string
+	memeCooldown     time.Duration
+	memeModel        models.MemeStore
+	settings         models.SettingsStore
+	usages           *usageRecorder
+	limiter          *replyLimiter
+	cooldowns        *cooldownCache
+	bot              *linebot.Client
+	commands         *commandRegistry
+	events           *eventRouter
+	processed        processedEvents
+	workers          *eventPool
+	pageTemplates    templateCache
+	metrics          *metrics
+	logger           *slog.Logger
+	hashLogIDs       bool    // Whether to hash the LINE user, group and room IDs in the logs.
+	db               *sql.DB // Nil for the in-memory storage.
+	dbConfig         config.DBConfig
+	storageReady     atomic.Bool // Whether the storage is connected and migrated.
+	server           *http.Server
+	shutdownTimeout  time.Duration
+	shutdownOnce     sync.Once
+	shutdownErr      error
 }
 
-// InitializeAndRun initializes the app with predefined configuration and run the app.
-// It tries to reconnect to the database indefinitely when it fails.
-func (a *App) InitializeAndRun(config *config.Config) {
-	// PostgreSQL connection.
-	var db *sql.DB
+// New initializes the app with the configuration, and starts the background workers. The
+// database is connected and migrated later by Run.
+func New(config *config.Config) (*App, error) {
+	a := &App{}
 
-	dbTicker := time.NewTicker(reconnectionInterval * time.Second)
-
-	// Database connection with retries.
-Loop:
-	for {
-		select {
-		case <-dbTicker.C:
-			log.Println("Trying to establish connection with the database...")
-			var err error
-			db, err = sql.Open(config.DB.Dialect, config.DB.ConnectionURL)
-			if err != nil {
-				log.Println(err)
-				continue Loop
-			}
+	// Structured logs. The standard logger writes through it as well.
+	logger, err := newLogger(config.Log, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	a.logger = logger
+	a.hashLogIDs = config.Log.HashSourceIDs
+	slog.SetDefault(logger)
 
-			if err = db.Ping(); err != nil {
-				log.Println(err)
-				continue Loop
-			}
+	// Metrics of the app.
+	a.metrics = newMetrics()
 
-			// Successfully connected to the database.
-			log.Println("Successfully connected to the database. The app is running.")
-			dbTicker.Stop()
-			defer db.Close()
-			break Loop
+	// Meme storage.
+	var memeStore store
+	if config.DB.Dialect == memoryDialect {
+		a.logger.Warn("using the in-memory meme storage, memes are lost when the app stops")
+		memeStore = models.NewMemoryMemeModel()
+	} else {
+		// The connection is established in the background by Run.
+		db, err := openDB(config.DB)
+		if err != nil {
+			return nil, err
 		}
-	}
+		a.db = db
+		a.dbConfig = config.DB
 
-	// Inject the DBs into the models.
-	a.memeModel = &models.MemeModel{DB: db}
+		// Inject the DBs into the models.
+		memeStore = &models.MemeModel{DB: a.db, Logger: a.logger}
+	}
+	timed := newTimedStore(memeStore, a.metrics)
+	a.memeModel = timed
+	a.settings = timed
 
 	// Start a new linebot client.
-	bot, err := linebot.New(config.LineBot.ChannelSecret, config.LineBot.ChannelAccessToken)
+	bot, err := linebot.New(config.LineBot.ChannelSecret, config.LineBot.ChannelAccessToken,
+		linebot.WithHTTPClient(a.metrics.lineHTTPClient()))
 	if err != nil {
-		log.Println("Error creating a linebot client. Shutting down.")
-		return
+		a.closeDB()
+		return nil, fmt.Errorf("creating a linebot client: %w", err)
 	}
 	a.bot = bot
 
 	// Compile page templates.
 	a.pageTemplates, err = newTemplateCache([]string{"./ui/html/home.html"})
 	if err != nil {
-		log.Println("Error compiling templates.")
-		return
+		a.closeDB()
+		return nil, fmt.Errorf("compiling templates: %w", err)
 	}
 
-	// Admin secret.
-	a.adminSecret = config.AdminSecret
+	// Authentication of the admin APIs.
+	if config.Admin.RequireSignature && config.Admin.KeyPepper == "" {
+		a.closeDB()
+		return nil, errors.New("ADMIN_REQUIRE_SIGNATURE needs ADMIN_KEY_PEPPER")
+	}
+	a.adminSecret = config.Admin.Secret
+	a.keyPepper = config.Admin.KeyPepper
+	a.requireSignature = config.Admin.RequireSignature
+	a.signatureMaxAge = config.Admin.SignatureMaxAge
+	a.trustProxy = config.Admin.TrustProxy
+	a.apiKeys = timed
+	a.audits = timed
+	a.trashRetention = config.Trash.Retention
+
+	// Rate limits of the meme replies.
+	a.limiter = &replyLimiter{
+		users:         newRateLimiter(config.RateLimit.UserPerMinute, config.RateLimit.UserBurst),
+		chats:         newRateLimiter(config.RateLimit.GroupPerMinute, config.RateLimit.GroupBurst),
+		slowDownReply: config.RateLimit.SlowDownReply,
+	}
+
+	// Default greeting and farewell memes.
+	a.greetingMeme = memeName(config.Chat.GreetingMeme)
+	a.farewellMeme = memeName(config.Chat.FarewellMeme)
+
+	// Meme cooldowns in the groups and rooms.
+	a.memeCooldown = config.Chat.MemeCooldown
+	a.cooldowns = newCooldownCache()
+
+	// Skip the redelivered webhook events and reject the replayed admin requests. In the
+	// database, both share the TTL so that neither purges the other early.
+	if config.Webhook.DedupStore == databaseDedupStore {
+		ttl := config.Webhook.DedupTTL
+		if ttl < 2*a.signatureMaxAge {
+			ttl = 2 * a.signatureMaxAge
+		}
+		stored := &storedEvents{store: timed, ttl: ttl, logger: a.logger}
+		a.processed = stored
+		a.signatures = stored
+	} else {
+		a.processed = newEventCache(config.Webhook.DedupTTL, config.Webhook.DedupSize)
+		a.signatures = newSignatureCache(a.signatureMaxAge)
+	}
+
+	// Chat commands and webhook events.
+	a.registerCommands()
+	a.registerEvents()
+
+	// Record meme usages and process the webhook events in the background.
+	a.statsHashSalt = config.Stats.HashSalt
+	a.usages = newUsageRecorder(a.memeModel, a.logger)
+	a.workers = newEventPool(config.Webhook.Workers, config.Webhook.QueueSize, config.Webhook.QueuePolicy,
+		a.metrics.countEvents(a.processEvent), a.logger)
+	a.metrics.registerEventPool(a.workers)
 
 	// Web server.
-	server := &http.Server{
+	a.server = &http.Server{
 		Addr:         config.Server.Port,
 		Handler:      a.routes(),
 		IdleTimeout:  config.Server.IdleTimeout,
 		ReadTimeout:  config.Server.ReadTimeout,
 		WriteTimeout: config.Server.WriteTimeout,
 	}
+	a.shutdownTimeout = config.Server.ShutdownTimeout
 
-	log.Printf("Starting meme linebot server on %s\n", config.Server.Port)
-	log.Fatal(server.ListenAndServe())
+	return a, nil
+}
+
+// Run serves the app until ctx is canceled (e.g. on SIGTERM) or the server fails, and then
+// shuts the app down within the shutdown timeout. The server starts right away, and reports
+// not ready until the storage is connected and migrated. Run also stops if the database
+// cannot be connected within the configured attempts or deadline.
+func (a *App) Run(ctx context.Context) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		a.logger.Info("starting the meme linebot server", "addr", a.server.Addr)
+		serverErr <- a.server.ListenAndServe()
+	}()
+
+	storageErr := make(chan error, 1)
+	go func() {
+		storageErr <- a.prepareStorage(ctx)
+	}()
+
+	var err error
+	for stopped := false; !stopped; {
+		select {
+		case err = <-serverErr:
+			a.logger.Error("server stopped, shutting down", "error", err)
+			stopped = true
+		case err = <-storageErr:
+			if err != nil {
+				a.logger.Error("storage unavailable, shutting down", "error", err)
+				stopped = true
+			}
+			// Never receive from it again.
+			storageErr = nil
+		case <-ctx.Done():
+			a.logger.Info("received a stop signal, shutting down")
+			stopped = true
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	return errors.Join(err, a.Shutdown(shutdownCtx))
+}
+
+// Shutdown stops the server gracefully, waiting for the in-flight requests, the queued webhook
+// events and the usages to finish, and then closes the database. It gives up waiting when ctx
+// is done. Only the first call takes effect.
+func (a *App) Shutdown(ctx context.Context) error {
+	a.shutdownOnce.Do(func() {
+		// Stop accepting requests, and wait for the in-flight ones.
+		err := a.server.Shutdown(ctx)
+
+		// Drain the queued events before the usages, since the events record usages.
+		drained := make(chan struct{})
+		go func() {
+			defer close(drained)
+			a.workers.close()
+			a.usages.close()
+		}()
+
+		select {
+		case <-drained:
+			a.logger.Info("background work drained")
+		case <-ctx.Done():
+			err = errors.Join(err, fmt.Errorf("draining background work: %w", ctx.Err()))
+		}
+
+		a.shutdownErr = errors.Join(err, a.closeDB())
+	})
+
+	return a.shutdownErr
+}
+
+// closeDB closes the database, if any.
+func (a *App) closeDB() error {
+	if a.db == nil {
+		return nil
+	}
+
+	return a.db.Close()
 }
 
 func (a *App) routes() http.Handler {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/", a.homepageHandler)
-	mux.HandleFunc("/callback", a.callbackHandler)
-	mux.HandleFunc("/add", a.addMeme)
-	mux.HandleFunc("/delete", a.deleteMeme)
+	// Each route is logged and instrumented with its pattern.
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.HandleFunc(pattern, a.logRequests(pattern, a.metrics.instrument(pattern, h)))
+	}
+
+	handle("/healthz", a.healthHandler)
+	handle("/readyz", a.readyHandler)
+	handle("/version", a.versionHandler)
+	mux.Handle("/metrics", a.metrics.handler())
+
+	// The rest need the storage.
+	handle("/", a.requireStorage(a.homepageHandler))
+	handle("/callback", a.requireStorage(a.callbackHandler))
+	handle("/add", a.requireStorage(a.requireScope(scopeMemesWrite, a.addMeme)))
+	handle("/delete", a.requireStorage(a.requireScope(scopeMemesDelete, a.deleteMeme)))
+	handle("/alias/add", a.requireStorage(a.requireScope(scopeMemesWrite, a.addAlias)))
+	handle("/alias/remove", a.requireStorage(a.requireScope(scopeMemesWrite, a.removeAlias)))
+	handle("/stats", a.requireStorage(a.requireScope(scopeStatsRead, a.getStats)))
+	handle("/search", a.requireStorage(a.requireScope(scopeMemesRead, a.searchMemes)))
+	handle("/trash", a.requireStorage(a.requireScope(scopeMemesRead, a.listTrash)))
+	handle("/trash/restore", a.requireStorage(a.requireScope(scopeMemesDelete, a.restoreMeme)))
+	handle("/trash/purge", a.requireStorage(a.requireScope(scopeMemesDelete, a.purgeTrash)))
+	handle("/audit", a.requireStorage(a.requireScope(scopeAuditRead, a.getAuditLog)))
 
 	// For static files on the home page.
 	fileServer := http.FileServer(http.Dir("./ui/static"))
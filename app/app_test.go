@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestRunShutdown(t *testing.T) {
+	// Stub. A slow event is queued when the app is stopped.
+	a := newTestApp(t)
+	a.usages = newUsageRecorder(a.memeModel, a.logger)
+	a.server = &http.Server{Addr: "127.0.0.1:0", Handler: a.routes()}
+	a.shutdownTimeout = time.Second
+
+	handled := make(chan struct{})
+	a.workers = newEventPool(1, 10, blockPolicy, func(job webhookJob) {
+		time.Sleep(50 * time.Millisecond)
+		close(handled)
+	}, a.logger)
+	a.workers.submit(context.Background(), webhookJob{event: &linebot.Event{Type: linebot.EventTypeMessage}})
+
+	// When.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := a.Run(ctx)
+
+	// Want.
+	if err != nil {
+		t.Errorf("Run() = %v, want nil", err)
+	}
+
+	select {
+	case <-handled:
+	default:
+		t.Error("Run() returned before the queued event was processed")
+	}
+
+	if ok := a.workers.submit(context.Background(), webhookJob{event: &linebot.Event{}}); ok {
+		t.Error("submit() after shutdown = true, want false")
+	}
+
+	if err = a.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown() = %v, want nil", err)
+	}
+}
+
+func TestRunServerError(t *testing.T) {
+	// Stub. The port is already in use.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	a := newTestApp(t)
+	a.usages = newUsageRecorder(a.memeModel, a.logger)
+	a.workers = newEventPool(1, 10, dropPolicy, a.processEvent, a.logger)
+	a.server = &http.Server{Addr: l.Addr().String(), Handler: a.routes()}
+	a.shutdownTimeout = time.Second
+
+	// When.
+	err = a.Run(context.Background())
+
+	// Want.
+	if err == nil {
+		t.Error("Run() = nil, want the listen error")
+	}
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+)
+
+// Actions of the audit log.
+const (
+	auditMemeAdd     = "meme.add"
+	auditMemeDelete  = "meme.delete"
+	auditMemeRestore = "meme.restore"
+	auditMemePurge   = "meme.purge"
+	auditAliasAdd    = "alias.add"
+	auditAliasRemove = "alias.remove"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// adminKeyKey is the context key of the name of the API key authenticating the request.
+type adminKeyKey struct{}
+
+// adminKeyFrom returns the name of the API key carried by ctx, or "" if there is none.
+func adminKeyFrom(ctx context.Context) string {
+	name, _ := ctx.Value(adminKeyKey{}).(string)
+	return name
+}
+
+// clientIP returns the IP address of the client. Behind a trusted proxy such as the Heroku
+// router, it is the last address in X-Forwarded-For, since the ones before it are given by the
+// client. Otherwise the header is ignored, since the client may set it.
+func (a *App) clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); a.trustProxy && forwarded != "" {
+		addrs := strings.Split(forwarded, ",")
+		return strings.TrimSpace(addrs[len(addrs)-1])
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// memeSnapshot is the state of a meme recorded in the audit log.
+type memeSnapshot struct {
+	Link    string   `json:"link"`
+	Aliases []string `json:"aliases"`
+}
+
+// snapshot returns the JSON state of the meme, or "" if it no longer exists.
+func (a *App) snapshot(ctx context.Context, memeID int) string {
+	aliases, err := a.memeModel.ListAliases(memeID)
+	if err != nil || len(aliases) == 0 {
+		return ""
+	}
+
+	link, err := a.memeModel.Get(aliases[0])
+	if err != nil {
+		a.log(ctx).Error("fetching the meme for the audit log", "meme_id", memeID, "error", err)
+		return ""
+	}
+
+	b, _ := json.Marshal(memeSnapshot{Link: link, Aliases: aliases})
+	return string(b)
+}
+
+// audit records a change made by the admin request. The change is already made, so a failure
+// is only logged.
+func (a *App) audit(r *http.Request, action, name, oldValue, newValue string) {
+	err := a.audits.RecordAudit(models.AuditEntry{
+		Actor:     adminKeyFrom(r.Context()),
+		Action:    action,
+		MemeName:  name,
+		OldValue:  oldValue,
+		NewValue:  newValue,
+		ClientIP:  a.clientIP(r),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		a.log(r.Context()).Error("recording the audit log", "action", action, "name", name, "error", err)
+	}
+}
+
+// getAuditLog is used by the admin to query the audit log, newest first. The response has the
+// cursor of the next page if there may be more entries.
+func (a *App) getAuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		return
+	}
+
+	// Retrieve the request body.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		a.log(r.Context()).Error("reading the request body", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Unmarshal json.
+	req := struct {
+		Actor  string    `json:"actor"`
+		Action string    `json:"action"`
+		Name   string    `json:"name"`
+		Since  time.Time `json:"since"`
+		Until  time.Time `json:"until"`
+		Before int       `json:"before"`
+		Limit  int       `json:"limit"`
+	}{}
+	err = json.Unmarshal(body, &req)
+	if err != nil || req.Before < 0 || req.Limit < 0 || req.Limit > maxAuditLimit {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if req.Limit == 0 {
+		req.Limit = defaultAuditLimit
+	}
+
+	// Query the database.
+	res := struct {
+		Entries []models.AuditEntry `json:"entries"`
+		Next    int                 `json:"next,omitempty"`
+	}{}
+	res.Entries, err = a.audits.AuditLog(models.AuditFilter{
+		Actor:    req.Actor,
+		Action:   req.Action,
+		MemeName: req.Name,
+		Since:    req.Since,
+		Until:    req.Until,
+		Before:   req.Before,
+		Limit:    req.Limit,
+	})
+	if err != nil {
+		a.log(r.Context()).Error("fetching the audit log", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if len(res.Entries) == req.Limit {
+		res.Next = res.Entries[len(res.Entries)-1].ID
+	}
+
+	// Success.
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
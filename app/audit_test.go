@@ -0,0 +1,162 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+)
+
+func TestClientIP(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName   string
+		trustProxy bool
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"Remote address", false, "192.0.2.1:1234", "", "192.0.2.1"},
+		{"Forwarded", true, "10.0.0.1:1234", "203.0.113.7", "203.0.113.7"},
+		{"Spoofed forwarded", true, "10.0.0.1:1234", "198.51.100.1, 203.0.113.7", "203.0.113.7"},
+		{"Untrusted forwarded", false, "192.0.2.1:1234", "203.0.113.7", "192.0.2.1"},
+		{"Trusted without forwarded", true, "192.0.2.1:1234", "", "192.0.2.1"},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			a := &App{trustProxy: tc.trustProxy}
+			r := httptest.NewRequest("POST", "/add", nil)
+			r.RemoteAddr = tc.remoteAddr
+			if tc.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+
+			// When.
+			got := a.clientIP(r)
+
+			// Want.
+			if got != tc.want {
+				t.Errorf("want %q; got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	// Stub. Behind a proxy.
+	a := newTestApp(t)
+	a.trustProxy = true
+	handler := a.routes()
+
+	// When. Every admin mutation is recorded, the failed ones are not.
+	for _, req := range []struct {
+		target string
+		body   string
+	}{
+		{"/add", `{"name": "ah", "link": "txt.png"}`},
+		{"/alias/add", `{"name": "ah", "alias": "oh"}`},
+		{"/alias/remove", `{"name": "ah"}`},
+		{"/delete", `{"name": "oh"}`},
+		{"/delete", `{"name": "oh"}`},
+	} {
+		r := newAdminRequest(req.target, testAdminSecret, req.body)
+		r.Header.Set("X-Forwarded-For", "203.0.113.7")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	// Want.
+	entries, err := a.audits.AuditLog(models.AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.AuditEntry{
+		{Action: auditMemeDelete, MemeName: "oh", OldValue: `{"link":"https://i.imgur.com/txt.png","aliases":["oh"]}`},
+		{Action: auditAliasRemove, MemeName: "ah", OldValue: `{"link":"https://i.imgur.com/txt.png","aliases":["ah","oh"]}`, NewValue: `{"link":"https://i.imgur.com/txt.png","aliases":["oh"]}`},
+		{Action: auditAliasAdd, MemeName: "oh", OldValue: `{"link":"https://i.imgur.com/txt.png","aliases":["ah"]}`, NewValue: `{"link":"https://i.imgur.com/txt.png","aliases":["ah","oh"]}`},
+		{Action: auditMemeAdd, MemeName: "ah", NewValue: `{"link":"https://i.imgur.com/txt.png","aliases":["ah"]}`},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("want %d entries; got %+v", len(want), entries)
+	}
+	for i, e := range entries {
+		if e.Actor != legacyKeyName || e.ClientIP != "203.0.113.7" || e.CreatedAt.IsZero() {
+			t.Errorf("entry %d: want actor %q from 203.0.113.7; got %+v", i, legacyKeyName, e)
+		}
+		if e.Action != want[i].Action || e.MemeName != want[i].MemeName || e.OldValue != want[i].OldValue || e.NewValue != want[i].NewValue {
+			t.Errorf("entry %d: want %+v; got %+v", i, want[i], e)
+		}
+	}
+}
+
+func TestGetAuditLog(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+	for _, e := range []models.AuditEntry{
+		{Actor: "uploader", Action: auditMemeAdd, MemeName: "ah"},
+		{Actor: "uploader", Action: auditMemeAdd, MemeName: "oh"},
+		{Actor: "admin", Action: auditMemeDelete, MemeName: "ah"},
+	} {
+		if err := a.audits.RecordAudit(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Testcases.
+	tests := []struct {
+		testName   string
+		secret     string
+		body       string
+		wantStatus int
+		wantIDs    []int
+		wantNext   int
+	}{
+		{"All", testAdminSecret, `{}`, http.StatusOK, []int{3, 2, 1}, 0},
+		{"First page", testAdminSecret, `{"limit": 2}`, http.StatusOK, []int{3, 2}, 2},
+		{"Next page", testAdminSecret, `{"limit": 2, "before": 2}`, http.StatusOK, []int{1}, 0},
+		{"Filtered", testAdminSecret, `{"actor": "uploader", "name": "ah"}`, http.StatusOK, []int{1}, 0},
+		{"Time range", testAdminSecret, `{"since": "2000-01-01T00:00:00Z", "until": "2000-01-02T00:00:00Z"}`, http.StatusOK, []int{}, 0},
+		{"Limit too large", testAdminSecret, `{"limit": 1000}`, http.StatusBadRequest, nil, 0},
+		{"Malformed time", testAdminSecret, `{"since": "yesterday"}`, http.StatusBadRequest, nil, 0},
+		{"Wrong secret", "guess", `{}`, http.StatusUnauthorized, nil, 0},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			rr := httptest.NewRecorder()
+			a.routes().ServeHTTP(rr, newAdminRequest("/audit", tc.secret, tc.body))
+
+			// Want.
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("want %v; got %v", tc.wantStatus, rr.Code)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+
+			res := struct {
+				Entries []models.AuditEntry `json:"entries"`
+				Next    int                 `json:"next"`
+			}{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+
+			ids := []int{}
+			for _, e := range res.Entries {
+				ids = append(ids, e.ID)
+			}
+			if !reflect.DeepEqual(ids, tc.wantIDs) || res.Next != tc.wantNext {
+				t.Errorf("want %v, next %v; got %v, next %v", tc.wantIDs, tc.wantNext, ids, res.Next)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+)
+
+// Scopes of the admin APIs.
+const (
+	scopeMemesRead   = "memes:read"
+	scopeMemesWrite  = "memes:write"
+	scopeMemesDelete = "memes:delete"
+	scopeStatsRead   = "stats:read"
+	scopeAuditRead   = "audit:read"
+)
+
+var allScopes = []string{scopeMemesRead, scopeMemesWrite, scopeMemesDelete, scopeStatsRead, scopeAuditRead}
+
+const (
+	// legacyKeyName is the name of the key given by ADMIN_SECRET, which has all scopes.
+	legacyKeyName = "admin"
+	apiKeyPrefix  = "mk_"
+
+	bearerScheme    = "Bearer"
+	signatureScheme = "HMAC-SHA256"
+
+	// maxSignatures is the number of signatures remembered in each instance to reject replayed
+	// requests, unless they are stored in the database.
+	maxSignatures = 10000
+	// signatureEventPrefix prefixes the signatures recorded along with the webhook events.
+	signatureEventPrefix = "signature:"
+)
+
+var (
+	errNoCredentials     = errors.New("no credentials")
+	errInvalidKey        = errors.New("invalid API key")
+	errRevokedKey        = errors.New("revoked API key")
+	errSignatureRequired = errors.New("signed request required")
+	errSigningDisabled   = errors.New("signed requests are not enabled")
+	errInvalidSignature  = errors.New("invalid signature")
+	errExpiredSignature  = errors.New("expired or future timestamp")
+	errReplayedRequest   = errors.New("replayed request")
+)
+
+// authErrors are the errors of rejected credentials, which are sent to the client as is.
+var authErrors = []error{
+	errNoCredentials, errInvalidKey, errRevokedKey, errSignatureRequired, errSigningDisabled,
+	errInvalidSignature, errExpiredSignature, errReplayedRequest,
+}
+
+// authError returns the error of rejected credentials which err is, or nil if err is not one.
+func authError(err error) error {
+	for _, e := range authErrors {
+		if errors.Is(err, e) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// validScope tells whether the scope is a scope of the admin APIs.
+func validScope(scope string) bool {
+	for _, s := range allScopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newAPIKey returns a new random API key.
+func newAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashAPIKey returns the hex-encoded SHA-256 of the key, which is stored instead of the key.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// signingSecret returns the hex-encoded secret which signs the requests of the key: the
+// HMAC-SHA256 of the key hash, keyed with the pepper. The pepper is only in the server config,
+// so the stored hash alone cannot sign requests.
+func signingSecret(pepper, keyHash string) string {
+	mac := hmac.New(sha256.New, []byte(pepper))
+	mac.Write([]byte(keyHash))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signRequest returns the hex-encoded signature of a request: the HMAC-SHA256, keyed with the
+// signing secret of the API key, of the method, path, Unix timestamp and hex-encoded SHA-256
+// of the body, separated by newlines.
+func signRequest(secret, method, path string, timestamp int64, body []byte) string {
+	bodySum := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%s", method, path, timestamp, hex.EncodeToString(bodySum[:]))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// requireScope responds 401 unless the request is authenticated with an API key, and 403
+// unless the key has the scope. The key name is attached to the context and logs of the request.
+func (a *App) requireScope(scope string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := a.authenticate(r)
+		if authErr := authError(err); authErr != nil {
+			a.log(r.Context()).Warn("admin authentication failed", "scope", scope, "error", err)
+			w.Header().Set("WWW-Authenticate", signatureScheme)
+			http.Error(w, authErr.Error(), http.StatusUnauthorized)
+			return
+		} else if errors.Is(err, errEventCacheFull) {
+			a.log(r.Context()).Warn("too many signed admin requests", "scope", scope)
+			http.Error(w, "too many signed requests", http.StatusServiceUnavailable)
+			return
+		} else if err != nil {
+			a.log(r.Context()).Error("authenticating the admin request", "scope", scope, "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		logger := a.log(r.Context()).With("admin_key", key.Name)
+		if !key.HasScope(scope) {
+			logger.Warn("admin key lacks the scope", "scope", scope)
+			http.Error(w, "missing scope "+scope, http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(withLogger(r.Context(), logger), adminKeyKey{}, key.Name)
+		h(w, r.WithContext(ctx))
+	}
+}
+
+// authenticate returns the API key of the request from its Authorization header, which is
+// either "Bearer <key>", or "HMAC-SHA256 key=<name>, ts=<unix time>, sig=<signature>" for
+// a signed request (see signRequest).
+func (a *App) authenticate(r *http.Request) (models.APIKey, error) {
+	scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	switch {
+	case scheme == bearerScheme && credentials != "":
+		if a.requireSignature {
+			return models.APIKey{}, errSignatureRequired
+		}
+		return a.bearerKey(credentials)
+	case scheme == signatureScheme:
+		return a.signedKey(r, credentials)
+	default:
+		return models.APIKey{}, errNoCredentials
+	}
+}
+
+// bearerKey returns the active API key.
+func (a *App) bearerKey(secret string) (models.APIKey, error) {
+	if a.adminSecret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(a.adminSecret)) == 1 {
+		return a.legacyKey(), nil
+	}
+
+	key, err := a.apiKeys.GetAPIKeyByHash(hashAPIKey(secret))
+	if errors.Is(err, models.ErrNoRecord) {
+		return key, errInvalidKey
+	} else if err != nil {
+		return key, err
+	}
+
+	if key.Revoked() {
+		return key, errRevokedKey
+	}
+
+	return key, nil
+}
+
+// signedKey verifies the signature of the request and returns its API key. Each signature is
+// accepted once, within the maximum age of its timestamp.
+func (a *App) signedKey(r *http.Request, credentials string) (models.APIKey, error) {
+	if a.keyPepper == "" {
+		return models.APIKey{}, errSigningDisabled
+	}
+
+	params := map[string]string{}
+	for _, param := range strings.Split(credentials, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		params[name] = value
+	}
+
+	timestamp, err := strconv.ParseInt(params["ts"], 10, 64)
+	if err != nil || params["key"] == "" || params["sig"] == "" {
+		return models.APIKey{}, errInvalidSignature
+	}
+
+	if age := time.Since(time.Unix(timestamp, 0)); age > a.signatureMaxAge || age < -a.signatureMaxAge {
+		return models.APIKey{}, errExpiredSignature
+	}
+
+	key := a.legacyKey()
+	if params["key"] != legacyKeyName || a.adminSecret == "" {
+		key, err = a.apiKeys.GetAPIKey(params["key"])
+		if errors.Is(err, models.ErrNoRecord) {
+			return key, errInvalidKey
+		} else if err != nil {
+			return key, err
+		}
+	}
+
+	if key.Revoked() {
+		return key, errRevokedKey
+	}
+
+	// Keep the body for the handler.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return key, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	want := signRequest(signingSecret(a.keyPepper, key.Hash), r.Method, r.URL.Path, timestamp, body)
+	if !hmac.Equal([]byte(params["sig"]), []byte(want)) {
+		return key, errInvalidSignature
+	}
+
+	ok, err := a.signatures.markProcessed(signatureEventPrefix + params["sig"])
+	if err != nil {
+		return key, err
+	} else if !ok {
+		return key, errReplayedRequest
+	}
+
+	return key, nil
+}
+
+// newSignatureCache returns the in-process cache of the signatures of the signed requests. A
+// signature is remembered while its timestamp may be accepted, and new signatures are rejected
+// rather than forgetting it early.
+func newSignatureCache(maxAge time.Duration) *eventCache {
+	c := newEventCache(2*maxAge, maxSignatures)
+	c.strict = true
+	return c
+}
+
+// legacyKey returns the key given by ADMIN_SECRET.
+func (a *App) legacyKey() models.APIKey {
+	return models.APIKey{Name: legacyKeyName, Hash: hashAPIKey(a.adminSecret), Scopes: allScopes}
+}
@@ -0,0 +1,319 @@
+package app
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+	"github.com/YuChaoGithub/meme-linebot/config"
+)
+
+const testSearchBody = `{"query": "就爛"}`
+
+// newSignedRequest returns a POST request to an admin API, signed with the key at the time.
+func newSignedRequest(target, name, key string, at time.Time, body string) *http.Request {
+	return newRequestSignedWith(target, name, signingSecret(testKeyPepper, hashAPIKey(key)), at, body)
+}
+
+// newRequestSignedWith returns a POST request to an admin API, signed with the secret at the time.
+func newRequestSignedWith(target, name, secret string, at time.Time, body string) *http.Request {
+	r := httptest.NewRequest("POST", target, strings.NewReader(body))
+	sig := signRequest(secret, "POST", target, at.Unix(), []byte(body))
+	r.Header.Set("Authorization", fmt.Sprintf("%s key=%s, ts=%d, sig=%s", signatureScheme, name, at.Unix(), sig))
+	return r
+}
+
+// newAuthTestApp returns a test app with the API keys "reader", "writer" and "revoked", whose
+// keys are their names prefixed by "key-".
+func newAuthTestApp(t *testing.T) *App {
+	a := newTestApp(t)
+
+	keys := []models.APIKey{
+		{Name: "reader", Scopes: []string{scopeMemesRead}},
+		{Name: "writer", Scopes: []string{scopeMemesWrite}},
+		{Name: "revoked", Scopes: allScopes, RevokedAt: time.Now()},
+	}
+	for _, key := range keys {
+		key.Hash = hashAPIKey("key-" + key.Name)
+		key.CreatedAt = time.Now()
+		if err := a.apiKeys.CreateAPIKey(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return a
+}
+
+func TestRequireScope(t *testing.T) {
+	now := time.Now()
+
+	// Testcases.
+	tests := []struct {
+		testName   string
+		req        *http.Request
+		wantStatus int
+	}{
+		{"Admin secret", newAdminRequest("/search", testAdminSecret, testSearchBody), http.StatusOK},
+		{"API key", newAdminRequest("/search", "key-reader", testSearchBody), http.StatusOK},
+		{"Missing scope", newAdminRequest("/search", "key-writer", testSearchBody), http.StatusForbidden},
+		{"Revoked key", newAdminRequest("/search", "key-revoked", testSearchBody), http.StatusUnauthorized},
+		{"Unknown key", newAdminRequest("/search", "guess", testSearchBody), http.StatusUnauthorized},
+		{"No credentials", httptest.NewRequest("POST", "/search", strings.NewReader(testSearchBody)), http.StatusUnauthorized},
+		{"Signed", newSignedRequest("/search", "reader", "key-reader", now, testSearchBody), http.StatusOK},
+		{"Signed with admin secret", newSignedRequest("/search", legacyKeyName, testAdminSecret, now, testSearchBody), http.StatusOK},
+		{"Signed with wrong key", newSignedRequest("/search", "reader", "key-writer", now, testSearchBody), http.StatusUnauthorized},
+		{"Signed with the stored hash", newRequestSignedWith("/search", "reader", hashAPIKey("key-reader"), now, testSearchBody), http.StatusUnauthorized},
+		{"Signed revoked key", newSignedRequest("/search", "revoked", "key-revoked", now, testSearchBody), http.StatusUnauthorized},
+		{"Expired signature", newSignedRequest("/search", "reader", "key-reader", now.Add(-time.Hour), testSearchBody), http.StatusUnauthorized},
+		{"Future signature", newSignedRequest("/search", "reader", "key-reader", now.Add(time.Hour), testSearchBody), http.StatusUnauthorized},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			a := newAuthTestApp(t)
+
+			// When.
+			rr := httptest.NewRecorder()
+			a.routes().ServeHTTP(rr, tc.req)
+
+			// Want.
+			if rr.Code != tc.wantStatus {
+				t.Errorf("want %v; got %v", tc.wantStatus, rr.Code)
+			}
+			if rr.Code == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") != signatureScheme {
+				t.Errorf("want WWW-Authenticate %q; got %q", signatureScheme, rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestRequireScopeStoreError(t *testing.T) {
+	// Stub. The API keys can not be read.
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	a := newAuthTestApp(t)
+	a.apiKeys = &models.MemeModel{DB: db}
+
+	// When.
+	rr := httptest.NewRecorder()
+	a.routes().ServeHTTP(rr, newAdminRequest("/search", "key-reader", testSearchBody))
+
+	// Want. The error is not sent to the client.
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("want %v; got %v", http.StatusInternalServerError, rr.Code)
+	}
+	if body := strings.TrimSpace(rr.Body.String()); body != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("want body %q; got %q", http.StatusText(http.StatusInternalServerError), body)
+	}
+}
+
+func TestSignedRequest(t *testing.T) {
+	// Stub.
+	a := newAuthTestApp(t)
+	handler := a.routes()
+
+	// When. The body is tampered with.
+	r := newSignedRequest("/search", "reader", "key-reader", time.Now(), testSearchBody)
+	r.Body = httptest.NewRequest("POST", "/search", strings.NewReader(`{"query": "xyz"}`)).Body
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	// Want.
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("tampered: want %v; got %v", http.StatusUnauthorized, rr.Code)
+	}
+
+	// When. The same request is sent twice.
+	r = newSignedRequest("/search", "reader", "key-reader", time.Now(), testSearchBody)
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		replayed := r.Clone(r.Context())
+		replayed.Body = httptest.NewRequest("POST", "/search", strings.NewReader(testSearchBody)).Body
+
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, replayed)
+		codes = append(codes, rr.Code)
+	}
+
+	// Want.
+	if codes[0] != http.StatusOK || codes[1] != http.StatusUnauthorized {
+		t.Errorf("replayed: want [200 401]; got %v", codes)
+	}
+
+	// When. The handler reads the body after the signature is verified.
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newSignedRequest("/search", "reader", "key-reader", time.Now(), `{"query": ""}`))
+
+	// Want.
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("empty query: want %v; got %v", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestReplayedRequest(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName   string
+		signatures func(a *App) processedEvents
+		wantStatus [3]int // The first request, the replayed one, and another.
+	}{
+		{"Memory", func(a *App) processedEvents {
+			return a.signatures
+		}, [3]int{200, 401, 200}},
+		{"Memory full", func(a *App) processedEvents {
+			c := newEventCache(time.Minute, 1)
+			c.strict = true
+			return c
+		}, [3]int{200, 401, 503}},
+		{"Database", func(a *App) processedEvents {
+			return &storedEvents{store: models.NewMemoryMemeModel(), ttl: time.Minute, logger: a.logger}
+		}, [3]int{200, 401, 200}},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			a := newAuthTestApp(t)
+			a.signatures = tc.signatures(a)
+			handler := a.routes()
+			now := time.Now()
+
+			for i, body := range []string{testSearchBody, testSearchBody, `{"query": "爛"}`} {
+				// When.
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, newSignedRequest("/search", "reader", "key-reader", now, body))
+
+				// Want.
+				if rr.Code != tc.wantStatus[i] {
+					t.Errorf("request %d: want %v; got %v", i, tc.wantStatus[i], rr.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestSigningDisabled(t *testing.T) {
+	// Stub. Without the pepper, no key can sign.
+	a := newAuthTestApp(t)
+	a.keyPepper = ""
+
+	// When.
+	rr := httptest.NewRecorder()
+	a.routes().ServeHTTP(rr, newRequestSignedWith("/search", "reader", signingSecret("", hashAPIKey("key-reader")), time.Now(), testSearchBody))
+
+	// Want.
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("want %v; got %v", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+func TestRequireSignature(t *testing.T) {
+	// Stub.
+	a := newAuthTestApp(t)
+	a.requireSignature = true
+	handler := a.routes()
+
+	// Testcases.
+	tests := []struct {
+		testName   string
+		req        *http.Request
+		wantStatus int
+	}{
+		{"Bearer", newAdminRequest("/search", "key-reader", testSearchBody), http.StatusUnauthorized},
+		{"Signed", newSignedRequest("/search", "reader", "key-reader", time.Now(), testSearchBody), http.StatusOK},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, tc.req)
+
+			// Want.
+			if rr.Code != tc.wantStatus {
+				t.Errorf("want %v; got %v", tc.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRunAPIKeys(t *testing.T) {
+	// Stub.
+	store := models.NewMemoryMemeModel()
+	out := &bytes.Buffer{}
+
+	// When.
+	admin := config.AdminConfig{Secret: testAdminSecret, KeyPepper: testKeyPepper}
+	if err := runAPIKeys(store, admin, []string{"create", "ci", scopeMemesRead, scopeStatsRead}, out); err != nil {
+		t.Fatal(err)
+	}
+
+	// Want. The printed key authenticates as the created key, and the printed signing secret
+	// signs its requests.
+	printed := map[string]string{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if name, value, ok := strings.Cut(line, ": "); ok {
+			printed[name] = value
+		}
+	}
+	key, err := store.GetAPIKeyByHash(hashAPIKey(printed["key"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := signingSecret(testKeyPepper, key.Hash); printed["signing secret"] != want {
+		t.Errorf("want signing secret %q; got %q", want, printed["signing secret"])
+	}
+	if key.Name != "ci" || !key.HasScope(scopeStatsRead) || key.HasScope(scopeMemesWrite) {
+		t.Errorf("want key ci with scopes memes:read and stats:read; got %+v", key)
+	}
+
+	// Testcases.
+	tests := []struct {
+		testName string
+		args     []string
+		wantErr  bool
+	}{
+		{"Duplicate name", []string{"create", "ci", scopeMemesRead}, true},
+		{"Reserved name", []string{"create", legacyKeyName, scopeMemesRead}, true},
+		{"Invalid scope", []string{"create", "bot", "memes:*"}, true},
+		{"No scopes", []string{"create", "bot"}, true},
+		{"List", []string{"list"}, false},
+		{"Revoke", []string{"revoke", "ci"}, false},
+		{"Revoke unknown key", []string{"revoke", "bot"}, true},
+		{"Signing secret", []string{"signing-secret", "ci"}, false},
+		{"Signing secret of the admin secret", []string{"signing-secret", legacyKeyName}, false},
+		{"Signing secret of unknown key", []string{"signing-secret", "bot"}, true},
+		{"Unknown command", []string{"rotate", "ci"}, true},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			err := runAPIKeys(store, admin, tc.args, &bytes.Buffer{})
+
+			// Want.
+			if (err != nil) != tc.wantErr {
+				t.Errorf("want error %v; got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	if key, _ = store.GetAPIKey("ci"); !key.Revoked() {
+		t.Error("want key ci revoked")
+	}
+}
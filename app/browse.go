@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+const (
+	// browsePageSize is the number of memes in a carousel. LINE allows at most 12 bubbles,
+	// including the one linking to the next page.
+	browsePageSize = 9
+	browseAction   = "browse"
+	browseAltText  = "梗圖列表 Meme list"
+	noMemesText    = "目前沒有梗圖 No memes yet."
+)
+
+// browseData returns the postback data which requests the page of memes after the cursor.
+func browseData(cursor int) string {
+	return postbackData(browseAction, url.Values{"cursor": {strconv.Itoa(cursor)}})
+}
+
+// browseCommand replies with the first page of memes.
+func (a *App) browseCommand(ctx context.Context, event *linebot.Event, args string) []linebot.SendingMessage {
+	return a.browsePage(ctx, 0)
+}
+
+// browsePostback handles the "next page" button of the meme carousel.
+func (a *App) browsePostback(ctx context.Context, event *linebot.Event, params url.Values) {
+	cursor, err := strconv.Atoi(params.Get("cursor"))
+	if err != nil || cursor < 0 {
+		a.log(ctx).Warn("invalid cursor to browse", "cursor", params.Get("cursor"))
+		return
+	}
+
+	if messages := a.browsePage(ctx, cursor); len(messages) > 0 {
+		a.reply(ctx, event.ReplyToken, messages...)
+	}
+}
+
+// browsePage returns a carousel of the memes after the cursor, ending with a button to the
+// next page if there are more.
+func (a *App) browsePage(ctx context.Context, cursor int) []linebot.SendingMessage {
+	// Get one more meme to tell whether there is a next page.
+	entries, err := a.memeModel.Page(cursor, browsePageSize+1)
+	if err != nil {
+		a.log(ctx).Error("fetching a page of memes", "cursor", cursor, "error", err)
+		return nil
+	}
+
+	if len(entries) == 0 {
+		return textMessages(noMemesText)
+	}
+
+	hasNext := len(entries) > browsePageSize
+	if hasNext {
+		entries = entries[:browsePageSize]
+	}
+
+	carousel := &linebot.CarouselContainer{Type: linebot.FlexContainerTypeCarousel}
+	for _, entry := range entries {
+		carousel.Contents = append(carousel.Contents, memeBubble(entry.Link, entry.Aliases))
+	}
+	if hasNext {
+		carousel.Contents = append(carousel.Contents, nextPageBubble(entries[len(entries)-1].ID))
+	}
+
+	return []linebot.SendingMessage{linebot.NewFlexMessage(browseAltText, carousel)}
+}
+
+// memeBubble returns a bubble with the meme image, its names and a button sending it.
+func memeBubble(link string, aliases []string) *linebot.BubbleContainer {
+	body := []linebot.FlexComponent{
+		&linebot.TextComponent{
+			Type:   linebot.FlexComponentTypeText,
+			Text:   aliases[0],
+			Weight: linebot.FlexTextWeightTypeBold,
+			Wrap:   true,
+		},
+	}
+	if len(aliases) > 1 {
+		body = append(body, &linebot.TextComponent{
+			Type:  linebot.FlexComponentTypeText,
+			Text:  strings.Join(aliases[1:], " / "),
+			Size:  linebot.FlexTextSizeTypeSm,
+			Color: "#999999",
+			Wrap:  true,
+		})
+	}
+
+	return &linebot.BubbleContainer{
+		Type: linebot.FlexContainerTypeBubble,
+		Hero: &linebot.ImageComponent{
+			Type:        linebot.FlexComponentTypeImage,
+			URL:         link,
+			Size:        linebot.FlexImageSizeTypeFull,
+			AspectRatio: linebot.FlexImageAspectRatioType1to1,
+			AspectMode:  linebot.FlexImageAspectModeTypeCover,
+		},
+		Body: &linebot.BoxComponent{
+			Type:     linebot.FlexComponentTypeBox,
+			Layout:   linebot.FlexBoxLayoutTypeVertical,
+			Contents: body,
+		},
+		Footer: &linebot.BoxComponent{
+			Type:   linebot.FlexComponentTypeBox,
+			Layout: linebot.FlexBoxLayoutTypeVertical,
+			Contents: []linebot.FlexComponent{
+				&linebot.ButtonComponent{
+					Type:   linebot.FlexComponentTypeButton,
+					Action: linebot.NewMessageAction("傳送 Send", aliases[0]),
+					Style:  linebot.FlexButtonStyleTypePrimary,
+				},
+			},
+		},
+	}
+}
+
+// nextPageBubble returns a bubble with a button to the page after the cursor.
+func nextPageBubble(cursor int) *linebot.BubbleContainer {
+	return &linebot.BubbleContainer{
+		Type: linebot.FlexContainerTypeBubble,
+		Body: &linebot.BoxComponent{
+			Type:   linebot.FlexComponentTypeBox,
+			Layout: linebot.FlexBoxLayoutTypeVertical,
+			Contents: []linebot.FlexComponent{
+				&linebot.ButtonComponent{
+					Type:   linebot.FlexComponentTypeButton,
+					Action: linebot.NewPostbackAction("下一頁 Next", browseData(cursor), "", "下一頁 Next"),
+				},
+			},
+		},
+	}
+}
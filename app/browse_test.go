@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestBrowsePage(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+	for i := 0; i < 11; i++ {
+		if err := a.memeModel.Insert(fmt.Sprint("meme", i), fmt.Sprint(i, ".png")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Page through the memes by following the next page buttons.
+	memes, pages := 0, 0
+	for cursor := 0; cursor >= 0; pages++ {
+		// When.
+		messages := a.browsePage(context.Background(), cursor)
+
+		// Want.
+		if len(messages) != 1 {
+			t.Fatalf("want 1 message; got %d", len(messages))
+		}
+		flex, ok := messages[0].(*linebot.FlexMessage)
+		if !ok {
+			t.Fatalf("want a flex message; got %T", messages[0])
+		}
+
+		cursor = -1
+		for _, bubble := range flex.Contents.(*linebot.CarouselContainer).Contents {
+			if bubble.Hero != nil {
+				memes++
+				continue
+			}
+
+			// The next page bubble.
+			button := bubble.Body.Contents[0].(*linebot.ButtonComponent)
+			data, err := url.ParseQuery(button.Action.(*linebot.PostbackAction).Data)
+			if err != nil || data.Get("action") != browseAction {
+				t.Fatalf("want a browse postback; got %v", button.Action)
+			}
+			if cursor, err = strconv.Atoi(data.Get("cursor")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if memes != 12 || pages != 2 {
+		t.Errorf("want 12 memes in 2 pages; got %d memes in %d pages", memes, pages)
+	}
+
+	// The carousel must be accepted by the SDK.
+	if _, err := json.Marshal(a.browsePage(context.Background(), 0)[0]); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBrowseEmpty(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+
+	// When.
+	messages := a.browsePage(context.Background(), 100)
+
+	// Want.
+	if text, ok := messages[0].(*linebot.TextMessage); !ok || text.Text != noMemesText {
+		t.Errorf("want %q; got %v", noMemesText, messages)
+	}
+}
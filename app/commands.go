@@ -0,0 +1,281 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+const (
+	commandPrefix      = "/"
+	keywordSuffix      = ".jpg"
+	searchResultsLimit = 5
+	recentMemesLimit   = 10
+
+	implicitOnReply    = "已開啟關鍵字模式：訊息與梗圖名稱完全相同時，不加 .jpg 也會回覆。\nImplicit keyword mode is on."
+	implicitOffReply   = "已關閉關鍵字模式：請在梗圖名稱後加上 .jpg。\nImplicit keyword mode is off."
+	implicitUsage      = "用法 Usage: /implicit on|off"
+	quickReplyOnReply  = "已開啟快速回覆：猜測梗圖時會附上相近的關鍵字按鈕。\nQuick replies are on."
+	quickReplyOffReply = "已關閉快速回覆。\nQuick replies are off."
+	quickReplyUsage    = "用法 Usage: /quickreply on|off"
+	searchUsage        = "用法 Usage: /search <關鍵字 keyword>"
+	noSearchResults    = "找不到相關的梗圖 No memes found."
+)
+
+// commandHandler handles a chat command and returns the messages to reply with.
+// args is the text after the command name, with surrounding spaces trimmed.
+type commandHandler func(ctx context.Context, event *linebot.Event, args string) []linebot.SendingMessage
+
+// command is a chat command such as "/search 爛".
+type command struct {
+	name        string // Including the prefix, e.g. "/search".
+	usage       string
+	description string
+	handler     commandHandler
+}
+
+// commandRegistry dispatches the chat commands to their handlers.
+// Adding a command is just registering a new handler.
+type commandRegistry struct {
+	commands map[string]command
+	names    []string // In the order of registration, for /help.
+}
+
+func newCommandRegistry() *commandRegistry {
+	return &commandRegistry{commands: map[string]command{}}
+}
+
+// register adds a command to the registry, replacing any command with the same name.
+func (r *commandRegistry) register(c command) {
+	if _, ok := r.commands[c.name]; !ok {
+		r.names = append(r.names, c.name)
+	}
+	r.commands[c.name] = c
+}
+
+// dispatch runs the command in the text and returns its reply messages.
+// It returns false if the text is not a registered command.
+func (r *commandRegistry) dispatch(ctx context.Context, event *linebot.Event, text string) ([]linebot.SendingMessage, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, commandPrefix) {
+		return nil, false
+	}
+
+	name, args := text, ""
+	if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+		name, args = text[:i], strings.TrimSpace(text[i:])
+	}
+
+	c, ok := r.commands[strings.ToLower(name)]
+	if !ok {
+		return nil, false
+	}
+
+	return c.handler(ctx, event, args), true
+}
+
+// help returns the usages and descriptions of all commands.
+func (r *commandRegistry) help() string {
+	lines := []string{"指令 Commands:"}
+	for _, name := range r.names {
+		c := r.commands[name]
+		lines = append(lines, c.usage+"\n  "+c.description)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// registerCommands builds the registry of all chat commands of the app.
+func (a *App) registerCommands() {
+	a.commands = newCommandRegistry()
+
+	a.commands.register(command{
+		name:        "/help",
+		usage:       "/help",
+		description: "顯示所有指令 Show all commands.",
+		handler:     a.helpCommand,
+	})
+	a.commands.register(command{
+		name:        "/search",
+		usage:       "/search <關鍵字 keyword>",
+		description: "搜尋梗圖 Search for memes.",
+		handler:     a.searchCommand,
+	})
+	a.commands.register(command{
+		name:        "/random",
+		usage:       "/random",
+		description: "隨機梗圖 Send a random meme.",
+		handler:     a.randomCommand,
+	})
+	a.commands.register(command{
+		name:        "/new",
+		usage:       "/new",
+		description: "最新梗圖 List the newest memes.",
+		handler:     a.newCommand,
+	})
+	a.commands.register(command{
+		name:        "/browse",
+		usage:       "/browse",
+		description: "瀏覽所有梗圖 Browse all memes.",
+		handler:     a.browseCommand,
+	})
+	a.commands.register(command{
+		name:        "/greeting",
+		usage:       "/greeting <梗圖名稱 meme name>|reset",
+		description: "設定新成員加入時的梗圖 Set the meme greeting new members.",
+		handler: a.memeSettingCommand(greetingUsage, func(s *models.ChatSettings, name string) {
+			s.GreetingMeme = name
+		}),
+	})
+	a.commands.register(command{
+		name:        "/farewell",
+		usage:       "/farewell <梗圖名稱 meme name>|reset",
+		description: "設定成員離開時的梗圖 Set the meme for leaving members.",
+		handler: a.memeSettingCommand(farewellUsage, func(s *models.ChatSettings, name string) {
+			s.FarewellMeme = name
+		}),
+	})
+	a.commands.register(command{
+		name:        "/cooldown",
+		usage:       "/cooldown <秒數 seconds>|off|reset",
+		description: "同一張梗圖在群組中再次傳送前的冷卻時間 Set how long before the same meme can be sent again.",
+		handler:     a.cooldownCommand,
+	})
+	a.commands.register(command{
+		name:        "/implicit",
+		usage:       "/implicit on|off",
+		description: "訊息與梗圖名稱相同時，不加 .jpg 也回覆 Reply to bare keywords without .jpg.",
+		handler: a.toggleCommand(implicitUsage, implicitOnReply, implicitOffReply, func(s *models.ChatSettings, on bool) {
+			s.ImplicitTrigger = on
+		}),
+	})
+	a.commands.register(command{
+		name:        "/quickreply",
+		usage:       "/quickreply on|off",
+		description: "猜測梗圖時附上相近的關鍵字按鈕 Show the nearest keywords as buttons on a guessed meme.",
+		handler: a.toggleCommand(quickReplyUsage, quickReplyOnReply, quickReplyOffReply, func(s *models.ChatSettings, on bool) {
+			s.NoQuickReplies = !on
+		}),
+	})
+}
+
+// handleCommand handles the chat commands. It returns false if the text is not a command.
+func (a *App) handleCommand(ctx context.Context, event *linebot.Event, text string) bool {
+	messages, ok := a.commands.dispatch(ctx, event, text)
+	if !ok {
+		return false
+	}
+
+	if len(messages) > 0 {
+		a.reply(ctx, event.ReplyToken, messages...)
+	}
+
+	return true
+}
+
+// helpCommand replies with the list of commands.
+func (a *App) helpCommand(ctx context.Context, event *linebot.Event, args string) []linebot.SendingMessage {
+	return textMessages(a.commands.help())
+}
+
+// searchCommand replies with the names of the memes most similar to the keyword.
+func (a *App) searchCommand(ctx context.Context, event *linebot.Event, args string) []linebot.SendingMessage {
+	if args == "" {
+		return textMessages(searchUsage)
+	}
+
+	results, err := a.memeModel.Search(strings.ToLower(args), searchResultsLimit)
+	if err != nil {
+		a.log(ctx).Error("searching memes", "query", args, "error", err)
+		return nil
+	}
+
+	if len(results) == 0 {
+		return textMessages(noSearchResults)
+	}
+
+	lines := []string{"搜尋結果 Results:"}
+	for i, result := range results {
+		lines = append(lines, fmt.Sprintf("%d. %v%v", i+1, result.Name, keywordSuffix))
+	}
+
+	return textMessages(strings.Join(lines, "\n"))
+}
+
+// randomCommand replies with a random meme and its names.
+func (a *App) randomCommand(ctx context.Context, event *linebot.Event, args string) []linebot.SendingMessage {
+	entry, err := a.memeModel.Random()
+	if err != nil {
+		a.log(ctx).Error("fetching a random meme", "error", err)
+		return nil
+	}
+
+	return []linebot.SendingMessage{
+		linebot.NewImageMessage(entry.Link, entry.Link),
+		linebot.NewTextMessage(strings.Join(entry.Aliases, " / ")),
+	}
+}
+
+// newCommand replies with the names of the newest memes.
+func (a *App) newCommand(ctx context.Context, event *linebot.Event, args string) []linebot.SendingMessage {
+	entries, err := a.memeModel.Recent(recentMemesLimit)
+	if err != nil {
+		a.log(ctx).Error("fetching the newest memes", "error", err)
+		return nil
+	}
+
+	lines := []string{"最新梗圖 Newest memes:"}
+	for _, entry := range entries {
+		lines = append(lines, strings.Join(entry.Aliases, " / "))
+	}
+
+	return textMessages(strings.Join(lines, "\n"))
+}
+
+// toggleCommand returns the handler of an "on|off" command which turns a setting of the chat
+// on or off with set.
+func (a *App) toggleCommand(usage, onReply, offReply string, set func(s *models.ChatSettings, on bool)) commandHandler {
+	return func(ctx context.Context, event *linebot.Event, args string) []linebot.SendingMessage {
+		args = strings.ToLower(args)
+		if args != "on" && args != "off" {
+			return textMessages(usage)
+		}
+
+		chatID := chatKey(event.Source)
+		settings, err := a.settings.GetChatSettings(chatID)
+		if err != nil {
+			a.log(ctx).Error("fetching the chat settings", "error", err)
+			return nil
+		}
+
+		on := args == "on"
+		set(&settings, on)
+		if err = a.settings.SaveChatSettings(chatID, settings); err != nil {
+			a.log(ctx).Error("saving the chat settings", "error", err)
+			return nil
+		}
+
+		if on {
+			return textMessages(onReply)
+		}
+
+		return textMessages(offReply)
+	}
+}
+
+// textMessages wraps the text into reply messages.
+func textMessages(text string) []linebot.SendingMessage {
+	return []linebot.SendingMessage{linebot.NewTextMessage(text)}
+}
+
+// reply replies to the event (with the replyToken) with the messages.
+func (a *App) reply(ctx context.Context, replyToken string, messages ...linebot.SendingMessage) {
+	_, err := a.bot.ReplyMessage(replyToken, messages...).Do()
+	if err != nil {
+		a.log(ctx).Error("sending the reply message", "error", err)
+	}
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestDispatchCommand(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+	if err := a.memeModel.Insert("快樂", "6UegMI2.png"); err != nil {
+		t.Fatal(err)
+	}
+	event := &linebot.Event{Source: &linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: "group"}}
+
+	// Testcases.
+	tests := []struct {
+		testName    string
+		text        string
+		wantCommand bool
+		wantTexts   []string // Substrings of the text messages, in order.
+		wantImages  int
+	}{
+		{"Not a command", "我就爛.jpg", false, nil, 0},
+		{"Unknown command", "/unknown", false, nil, 0},
+		{"Help", "/help", true, []string{"/search"}, 0},
+		{"Case insensitive", " /HELP ", true, []string{"/random"}, 0},
+		{"Search", "/search 就爛", true, []string{"1. 我就爛.jpg"}, 0},
+		{"Search full-width space", "/search　就爛", true, []string{"1. 我就爛.jpg"}, 0},
+		{"Search without keyword", "/search", true, []string{searchUsage}, 0},
+		{"Search no results", "/search xyz", true, []string{noSearchResults}, 0},
+		{"Random", "/random", true, []string{".jpg"}, 1},
+		{"New", "/new", true, []string{"快樂.jpg\n我就爛.jpg"}, 0},
+		{"Implicit", "/implicit on", true, []string{implicitOnReply}, 0},
+		{"Implicit usage", "/implicit maybe", true, []string{implicitUsage}, 0},
+		{"Quick reply", "/quickreply off", true, []string{quickReplyOffReply}, 0},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			messages, ok := a.commands.dispatch(context.Background(), event, tc.text)
+
+			// Want.
+			if ok != tc.wantCommand {
+				t.Fatalf("want command %v; got %v", tc.wantCommand, ok)
+			}
+
+			texts, images := []string{}, 0
+			for _, message := range messages {
+				switch m := message.(type) {
+				case *linebot.TextMessage:
+					texts = append(texts, m.Text)
+				case *linebot.ImageMessage:
+					images++
+				}
+			}
+
+			if len(texts) != len(tc.wantTexts) {
+				t.Fatalf("want %d text messages; got %q", len(tc.wantTexts), texts)
+			}
+			for i, want := range tc.wantTexts {
+				if !strings.Contains(texts[i], want) {
+					t.Errorf("want %q in text message; got %q", want, texts[i])
+				}
+			}
+
+			if images != tc.wantImages {
+				t.Errorf("want %d image messages; got %d", tc.wantImages, images)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+const (
+	// maxCooldowns is the number of entries above which the expired ones are dropped.
+	maxCooldowns  = 10000
+	cooldownUsage = "用法 Usage: /cooldown <秒數 seconds>|off|reset"
+	cooldownReply = "冷卻時間 Cooldown: %v"
+)
+
+// cooldownCache remembers which keys are cooling down, until their expiry. It is safe for
+// concurrent use.
+type cooldownCache struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+	now   func() time.Time
+}
+
+func newCooldownCache() *cooldownCache {
+	return &cooldownCache{
+		until: map[string]time.Time{},
+		now:   time.Now,
+	}
+}
+
+// coolingDown tells whether the key is still cooling down.
+func (c *cooldownCache) coolingDown(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until, ok := c.until[key]
+	return ok && c.now().Before(until)
+}
+
+// start starts the cooldown of the key for d.
+func (c *cooldownCache) start(key string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if len(c.until) >= maxCooldowns {
+		c.dropExpired(now)
+	}
+	c.until[key] = now.Add(d)
+}
+
+// dropExpired removes the keys which are no longer cooling down. The caller must hold the lock.
+func (c *cooldownCache) dropExpired(now time.Time) {
+	for key, until := range c.until {
+		if !now.Before(until) {
+			delete(c.until, key)
+		}
+	}
+}
+
+// cooldownOf returns the ID of the group or room and its meme cooldown. The cooldown is zero
+// if it is turned off, and for one-on-one chats, which have none.
+func (a *App) cooldownOf(ctx context.Context, source *linebot.EventSource) (string, time.Duration) {
+	chatID := source.GroupID
+	if chatID == "" {
+		chatID = source.RoomID
+	}
+	if chatID == "" {
+		return "", 0
+	}
+
+	d := a.memeCooldown
+	if settings, ok := a.chatSettings(ctx, source); ok && settings.MemeCooldown != 0 {
+		d = time.Duration(settings.MemeCooldown) * time.Second
+	}
+	if d < 0 {
+		d = 0
+	}
+
+	return chatID, d
+}
+
+// coolDown removes the matches sent in the chat within its cooldown d.
+func (a *App) coolDown(chatID string, d time.Duration, matches []memeMatch) []memeMatch {
+	if d == 0 {
+		return matches
+	}
+
+	res := []memeMatch{}
+	for _, match := range matches {
+		if !a.cooldowns.coolingDown(chatID + " " + match.url) {
+			res = append(res, match)
+		}
+	}
+
+	return res
+}
+
+// startCooldown starts the cooldown d of the matches sent in the chat.
+func (a *App) startCooldown(chatID string, d time.Duration, matches []memeMatch) {
+	if d == 0 {
+		return
+	}
+
+	for _, match := range matches {
+		a.cooldowns.start(chatID+" "+match.url, d)
+	}
+}
+
+// cooldownCommand sets the meme cooldown of the chat.
+func (a *App) cooldownCommand(ctx context.Context, event *linebot.Event, args string) []linebot.SendingMessage {
+	seconds := 0
+	switch args = strings.ToLower(args); args {
+	case "off":
+		seconds = -1
+	case "reset":
+		seconds = 0
+	default:
+		n, err := strconv.Atoi(args)
+		if err != nil || n < 0 {
+			return textMessages(cooldownUsage)
+		}
+
+		// 0 seconds is the same as off, since 0 means the default in the settings.
+		seconds = n
+		if n == 0 {
+			seconds = -1
+		}
+	}
+
+	settings, err := a.settings.GetChatSettings(chatKey(event.Source))
+	if err != nil {
+		a.log(ctx).Error("fetching the chat settings", "error", err)
+		return nil
+	}
+
+	settings.MemeCooldown = seconds
+	if err = a.settings.SaveChatSettings(chatKey(event.Source), settings); err != nil {
+		a.log(ctx).Error("saving the chat settings", "error", err)
+		return nil
+	}
+
+	switch {
+	case seconds < 0:
+		return textMessages(fmt.Sprintf(cooldownReply, "關閉 off"))
+	case seconds == 0:
+		return textMessages(fmt.Sprintf(cooldownReply, fmt.Sprintf("預設 default (%v)", a.memeCooldown)))
+	default:
+		return textMessages(fmt.Sprintf(cooldownReply, time.Duration(seconds)*time.Second))
+	}
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestCooldownCache(t *testing.T) {
+	// Stub.
+	c := newCooldownCache()
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	// Testcases.
+	tests := []struct {
+		testName string
+		elapsed  time.Duration
+		key      string
+		start    bool
+		want     bool // Whether the key is cooling down.
+	}{
+		{"Not started", 0, "a", false, false},
+		{"First", 0, "a", true, true},
+		{"Cooling down", 5 * time.Second, "a", false, true},
+		{"Other keys", 0, "b", false, false},
+		{"Expired", 5 * time.Second, "a", false, false},
+		{"Restarted", 0, "a", true, true},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			now = now.Add(tc.elapsed)
+
+			// When.
+			if tc.start {
+				c.start(tc.key, 10*time.Second)
+			}
+			got := c.coolingDown(tc.key)
+
+			// Want.
+			if got != tc.want {
+				t.Errorf("want %v; got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCoolDown(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+	group := &linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: "group", UserID: "user"}
+	user := &linebot.EventSource{Type: linebot.EventSourceTypeUser, UserID: "user"}
+	matches := []memeMatch{{keyword: "我就爛", url: "https://i.imgur.com/t9WaxTw.png"}}
+
+	// Testcases.
+	tests := []struct {
+		testName  string
+		command   string
+		source    *linebot.EventSource
+		wantReply string
+		wantSent  bool
+	}{
+		{"First", "", group, "", true},
+		{"Cooling down", "", group, "", false},
+		{"One-on-one chats", "", user, "", true},
+		{"Turned off", "/cooldown off", group, "冷卻時間 Cooldown: 關閉 off", true},
+		{"Zero is off", "/cooldown 0", group, "冷卻時間 Cooldown: 關閉 off", true},
+		{"Reset", "/cooldown reset", group, "冷卻時間 Cooldown: 預設 default (10s)", false},
+		{"Usage", "/cooldown -5", group, cooldownUsage, false},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			if tc.command != "" {
+				messages, _ := a.commands.dispatch(context.Background(), &linebot.Event{Source: tc.source}, tc.command)
+				if text := messages[0].(*linebot.TextMessage).Text; text != tc.wantReply {
+					t.Errorf("want reply %q; got %q", tc.wantReply, text)
+				}
+			}
+
+			// When. The reply is sent.
+			chatID, d := a.cooldownOf(context.Background(), tc.source)
+			sent := a.coolDown(chatID, d, matches)
+			a.startCooldown(chatID, d, sent)
+
+			// Want.
+			if (len(sent) == 1) != tc.wantSent {
+				t.Errorf("want sent %v; got %v", tc.wantSent, sent)
+			}
+		})
+	}
+}
+
+func TestCooldownAfterFailedReply(t *testing.T) {
+	// Stub. A fake LINE API which fails the first reply.
+	replies := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replies++
+		if replies == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	a := newTestApp(t)
+	bot, err := linebot.New("secret", "token", linebot.WithEndpointBase(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.bot = bot
+	a.usages = newUsageRecorder(a.memeModel, a.logger)
+	group := &linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: "group", UserID: "user"}
+
+	// When.
+	for i := 0; i < 3; i++ {
+		a.replyWithMeme(context.Background(), "token", group, "我就爛.jpg")
+	}
+	a.usages.close()
+
+	// Want. The meme is sent again after the failed reply, then cools down.
+	if replies != 2 {
+		t.Errorf("want %d replies; got %d", 2, replies)
+	}
+}
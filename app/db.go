@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/config"
+	"github.com/YuChaoGithub/meme-linebot/database"
+)
+
+// openDB returns a connection pool of the database described by the config. It does not
+// connect to the database yet.
+func openDB(config config.DBConfig) (*sql.DB, error) {
+	db, err := sql.Open(config.Dialect, config.ConnectionURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Broken connections are replaced by the pool, so that the app recovers when the
+	// database comes back after a restart or a failover.
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+	return db, nil
+}
+
+// backoff computes the exponentially growing delays between the retries, with jitter so that
+// the instances do not retry in lockstep.
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+	attempt int
+	random  func() float64 // In [0, 1).
+}
+
+func newBackoff(initial, max time.Duration) *backoff {
+	if initial <= 0 {
+		initial = time.Second
+	}
+	if max < initial {
+		max = initial
+	}
+
+	return &backoff{initial: initial, max: max, random: rand.Float64}
+}
+
+// next returns the delay before the next retry: a random duration between half and all of
+// initial * 2^attempt, capped at max.
+func (b *backoff) next() time.Duration {
+	d := b.max
+	if b.attempt < 32 && b.initial<<b.attempt < b.max {
+		d = b.initial << b.attempt
+	}
+	b.attempt++
+
+	return d/2 + time.Duration(b.random()*float64(d/2))
+}
+
+// waitForDB pings the database until it answers, backing off between the attempts. It gives up
+// after config.ConnectMaxAttempts attempts or config.ConnectTimeout (no limit if 0), or when
+// ctx is done.
+func waitForDB(ctx context.Context, db *sql.DB, config config.DBConfig, logger *slog.Logger) error {
+	if config.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.ConnectTimeout)
+		defer cancel()
+	}
+
+	b := newBackoff(config.RetryInitial, config.RetryMax)
+	for attempt := 1; ; attempt++ {
+		logger.Info("connecting to the database", "attempt", attempt)
+		err := db.PingContext(ctx)
+		if err == nil {
+			logger.Info("connected to the database")
+			return nil
+		}
+
+		if config.ConnectMaxAttempts > 0 && attempt >= config.ConnectMaxAttempts {
+			return fmt.Errorf("connecting to the database: giving up after %d attempts: %w", attempt, err)
+		}
+
+		delay := b.next()
+		logger.Warn("connecting to the database failed, retrying", "error", err, "delay", delay.Round(time.Millisecond))
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("connecting to the database: %w (last error: %v)", ctx.Err(), err)
+		}
+	}
+}
+
+// prepareStorage connects to the database and brings the schema up to date, and then marks
+// the storage ready. The in-memory storage is ready right away.
+func (a *App) prepareStorage(ctx context.Context) error {
+	if a.db != nil {
+		if err := waitForDB(ctx, a.db, a.dbConfig, a.logger); err != nil {
+			return err
+		}
+
+		migrator, err := database.NewMigrator(a.db, a.dbConfig.Dialect)
+		if err != nil {
+			return err
+		}
+
+		applied, err := migrator.Up()
+		if err != nil {
+			return fmt.Errorf("migrating the database: %w", err)
+		}
+		a.logger.Info("migrated the database", "applied", applied)
+	}
+
+	a.storageReady.Store(true)
+	a.logger.Info("the storage is ready, the app is running")
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/config"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestBackoff(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName string
+		random   float64
+		want     []time.Duration
+	}{
+		{"No jitter", 0, []time.Duration{50, 100, 200, 400, 500, 500}},
+		{"Full jitter", 0.5, []time.Duration{75, 150, 300, 600, 750, 750}},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			b := newBackoff(100*time.Millisecond, time.Second)
+			b.random = func() float64 { return tc.random }
+
+			for i, want := range tc.want {
+				// When.
+				got := b.next()
+
+				// Want.
+				if want *= time.Millisecond; got != want {
+					t.Errorf("delay %d: want %v; got %v", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWaitForDB(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName      string
+		connectionURL string
+		wantErr       bool
+	}{
+		{"Connected", ":memory:", false},
+		{"Unreachable", "file:/nonexistent/memes.db?mode=ro", true},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			c := config.DBConfig{
+				Dialect:            "sqlite",
+				ConnectionURL:      tc.connectionURL,
+				RetryInitial:       time.Millisecond,
+				RetryMax:           time.Millisecond,
+				ConnectMaxAttempts: 3,
+			}
+			db, err := openDB(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			// When.
+			err = waitForDB(context.Background(), db, c, slog.Default())
+
+			// Want.
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("want error %v; got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestStorageReadiness(t *testing.T) {
+	// Stub. The storage is not prepared yet.
+	a := newTestApp(t)
+	a.storageReady.Store(false)
+	a.bot = &linebot.Client{}
+	handler := a.routes()
+
+	// Testcases.
+	tests := []struct {
+		path      string
+		wantCodes [2]int // Before and after the storage is ready.
+	}{
+		{"/readyz", [2]int{503, 200}},
+		{"/", [2]int{503, 200}},
+		{"/stats", [2]int{503, 401}}, // Not authenticated.
+	}
+
+	// Perform tests.
+	for i, ready := range []bool{false, true} {
+		if ready {
+			if err := a.prepareStorage(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, tc := range tests {
+			// When.
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+
+			// Want.
+			if rr.Code != tc.wantCodes[i] {
+				t.Errorf("%v (ready %v): want %v; got %v", tc.path, ready, tc.wantCodes[i], rr.Code)
+			}
+		}
+	}
+}
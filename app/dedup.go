@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+)
+
+// processedEvents remembers the processed webhook events, so that an event redelivered by LINE
+// is processed only once.
+type processedEvents interface {
+	// markProcessed records the event. It returns false if the event is already processed.
+	markProcessed(eventID string) (bool, error)
+}
+
+// errEventCacheFull is returned by a strict eventCache which is full of unexpired events.
+var errEventCacheFull = errors.New("too many events within the TTL")
+
+// eventCache is a bounded in-process TTL cache of the processed events, for a single instance
+// of the app. It is safe for concurrent use.
+type eventCache struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	size   int
+	strict bool                 // Reject new events when full, instead of evicting the unexpired ones.
+	seen   map[string]time.Time // Event ID to the processed time.
+	order  []string             // Event IDs in the processed order, oldest first.
+	now    func() time.Time
+}
+
+func newEventCache(ttl time.Duration, size int) *eventCache {
+	return &eventCache{
+		ttl:  ttl,
+		size: size,
+		seen: map[string]time.Time{},
+		now:  time.Now,
+	}
+}
+
+// markProcessed records the event. It returns false if the event is processed within the TTL.
+func (c *eventCache) markProcessed(eventID string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+
+	// Evict the expired events.
+	for len(c.order) > 0 && now.Sub(c.seen[c.order[0]]) >= c.ttl {
+		c.evictOldest()
+	}
+
+	if _, ok := c.seen[eventID]; ok {
+		return false, nil
+	}
+
+	// Make room for the event.
+	if c.strict && len(c.order) >= c.size {
+		return false, errEventCacheFull
+	}
+	for len(c.order) > 0 && len(c.order) >= c.size {
+		c.evictOldest()
+	}
+
+	c.seen[eventID] = now
+	c.order = append(c.order, eventID)
+
+	return true, nil
+}
+
+// evictOldest forgets the oldest event. The caller must hold the lock.
+func (c *eventCache) evictOldest() {
+	delete(c.seen, c.order[0])
+	c.order = c.order[1:]
+}
+
+// storedEvents records the processed events in the storage, shared by all instances of the app.
+// The events older than the TTL are purged at most once per TTL.
+type storedEvents struct {
+	store  models.EventStore
+	ttl    time.Duration
+	logger *slog.Logger
+
+	mu         sync.Mutex
+	lastPurged time.Time
+}
+
+// markProcessed records the event. It returns false if the event is already processed.
+func (s *storedEvents) markProcessed(eventID string) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	purge := now.Sub(s.lastPurged) >= s.ttl
+	if purge {
+		s.lastPurged = now
+	}
+	s.mu.Unlock()
+
+	if purge {
+		if err := s.store.PurgeEvents(now.Add(-s.ttl)); err != nil {
+			s.logger.Error("purging the processed events", "error", err)
+		}
+	}
+
+	return s.store.MarkEvent(eventID, now)
+}
+
+// webhookDelivery is the delivery information of a webhook event, which the linebot SDK does
+// not parse.
+type webhookDelivery struct {
+	WebhookEventID  string `json:"webhookEventId"`
+	DeliveryContext struct {
+		IsRedelivery bool `json:"isRedelivery"`
+	} `json:"deliveryContext"`
+}
+
+// webhookDeliveries returns the delivery information of each event in the webhook request body.
+// It returns nil if the body does not have n events.
+func webhookDeliveries(body []byte, n int) []webhookDelivery {
+	req := struct {
+		Events []webhookDelivery `json:"events"`
+	}{}
+	if err := json.Unmarshal(body, &req); err != nil || len(req.Events) != n {
+		return nil
+	}
+
+	return req.Events
+}
+
+// isProcessed tells whether the event is already processed, and records it otherwise.
+// Events without an ID are never skipped, and neither are they when the record fails.
+func (a *App) isProcessed(ctx context.Context, eventID string) bool {
+	if a.processed == nil || eventID == "" {
+		return false
+	}
+
+	ok, err := a.processed.markProcessed(eventID)
+	if err != nil {
+		a.log(ctx).Error("recording the processed event", "error", err)
+		return false
+	}
+
+	return !ok
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestEventCache(t *testing.T) {
+	// Stub.
+	c := newEventCache(time.Minute, 2)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	// Testcases.
+	tests := []struct {
+		testName string
+		elapsed  time.Duration
+		eventID  string
+		want     bool
+	}{
+		{"New", 0, "e1", true},
+		{"Duplicate", 0, "e1", false},
+		{"Another", 10 * time.Second, "e2", true},
+		{"Still remembered", 40 * time.Second, "e1", false},
+		{"Expired", 10 * time.Second, "e1", true},
+		{"Evicted when full", 0, "e3", true},
+		{"Oldest evicted", 0, "e2", true},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			now = now.Add(tc.elapsed)
+
+			// When.
+			got, err := c.markProcessed(tc.eventID)
+
+			// Want.
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("want %v; got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStrictEventCache(t *testing.T) {
+	// Stub.
+	c := newEventCache(time.Minute, 1)
+	c.strict = true
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	// Testcases.
+	tests := []struct {
+		testName string
+		elapsed  time.Duration
+		eventID  string
+		want     bool
+		wantErr  error
+	}{
+		{"New", 0, "e1", true, nil},
+		{"Rejected when full", 30 * time.Second, "e2", false, errEventCacheFull},
+		{"Still remembered", 0, "e1", false, nil},
+		{"Expired", 30 * time.Second, "e2", true, nil},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			now = now.Add(tc.elapsed)
+
+			// When.
+			got, err := c.markProcessed(tc.eventID)
+
+			// Want.
+			if err != tc.wantErr {
+				t.Fatalf("want error %v; got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("want %v; got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCallbackDedup(t *testing.T) {
+	// Stub.
+	const channelSecret = "channel secret"
+	a := newTestApp(t)
+	a.processed = newEventCache(time.Minute, 100)
+	a.workers = newEventPool(1, 10, blockPolicy, a.processEvent, a.logger)
+	bot, err := linebot.New(channelSecret, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.bot = bot
+
+	routed := []string{}
+	a.events.handle(linebot.EventTypeUnfollow, func(ctx context.Context, event *linebot.Event) {
+		routed = append(routed, event.Source.UserID)
+	})
+
+	webhook := func(userID, eventID string, redelivery bool) string {
+		return fmt.Sprintf(`{"destination": "bot", "events": [{"type": "unfollow", "timestamp": 0,
+			"source": {"type": "user", "userId": %q}, "webhookEventId": %q,
+			"deliveryContext": {"isRedelivery": %v}}]}`, userID, eventID, redelivery)
+	}
+
+	// Testcases.
+	tests := []struct {
+		testName string
+		body     string
+	}{
+		{"New", webhook("u1", "e1", false)},
+		{"Redelivered", webhook("u1", "e1", true)},
+		{"Another", webhook("u2", "e2", false)},
+		{"No event ID", webhook("u3", "", false)},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			mac := hmac.New(sha256.New, []byte(channelSecret))
+			mac.Write([]byte(tc.body))
+			r := httptest.NewRequest("POST", "/callback", strings.NewReader(tc.body))
+			r.Header.Set("X-Line-Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+			// When.
+			rr := httptest.NewRecorder()
+			a.callbackHandler(rr, r)
+
+			// Want.
+			if rr.Code != http.StatusOK {
+				t.Fatalf("want %v; got %v", http.StatusOK, rr.Code)
+			}
+		})
+	}
+
+	// Wait for the queued events.
+	a.workers.close()
+	if want := []string{"u1", "u2", "u3"}; fmt.Sprint(routed) != fmt.Sprint(want) {
+		t.Errorf("want routed %v; got %v", want, routed)
+	}
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// errNoPostbackAction is returned when the postback data has no action.
+var errNoPostbackAction = errors.New("app: no action in postback data")
+
+// eventHandler handles a LINE webhook event of a type. The ctx carries the logger of the event.
+type eventHandler func(ctx context.Context, event *linebot.Event)
+
+// postbackHandler handles a postback action with the parameters in the postback data.
+type postbackHandler func(ctx context.Context, event *linebot.Event, params url.Values)
+
+// eventRouter routes the LINE webhook events to their handlers by event type, and the
+// postback events further by action. Events without a handler are ignored.
+type eventRouter struct {
+	events    map[linebot.EventType]eventHandler
+	postbacks map[string]postbackHandler
+	logger    *slog.Logger // Used when ctx carries no logger.
+}
+
+func newEventRouter(logger *slog.Logger) *eventRouter {
+	r := &eventRouter{
+		events:    map[linebot.EventType]eventHandler{},
+		postbacks: map[string]postbackHandler{},
+		logger:    logger,
+	}
+	r.events[linebot.EventTypePostback] = r.routePostback
+
+	return r
+}
+
+// handle sets the handler of the event type.
+func (r *eventRouter) handle(eventType linebot.EventType, h eventHandler) {
+	r.events[eventType] = h
+}
+
+// handlePostback sets the handler of the postback action.
+func (r *eventRouter) handlePostback(action string, h postbackHandler) {
+	r.postbacks[action] = h
+}
+
+// route calls the handler of the event.
+func (r *eventRouter) route(ctx context.Context, event *linebot.Event) {
+	if h, ok := r.events[event.Type]; ok {
+		h(ctx, event)
+	}
+}
+
+// routePostback calls the handler of the action in the postback data. Unknown or malformed
+// postbacks are logged and ignored, since anyone can send any postback data.
+func (r *eventRouter) routePostback(ctx context.Context, event *linebot.Event) {
+	if event.Postback == nil {
+		return
+	}
+
+	logger := loggerFrom(ctx, r.logger)
+	action, params, err := parsePostback(event.Postback.Data)
+	if err != nil {
+		logger.Warn("invalid postback", "data", event.Postback.Data, "error", err)
+		return
+	}
+
+	h, ok := r.postbacks[action]
+	if !ok {
+		logger.Warn("unknown postback action", "action", action)
+		return
+	}
+
+	h(ctx, event, params)
+}
+
+// postbackData encodes the action and its parameters into postback data, e.g.
+// "action=browse&cursor=9".
+func postbackData(action string, params url.Values) string {
+	data := url.Values{"action": {action}}
+	for key, values := range params {
+		data[key] = values
+	}
+
+	return data.Encode()
+}
+
+// parsePostback decodes the postback data made by postbackData.
+func parsePostback(data string) (string, url.Values, error) {
+	params, err := url.ParseQuery(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	action := params.Get("action")
+	if action == "" {
+		return "", nil, errNoPostbackAction
+	}
+	params.Del("action")
+
+	return action, params, nil
+}
+
+// registerEvents builds the router of all webhook events of the app.
+func (a *App) registerEvents() {
+	a.events = newEventRouter(a.logger)
+
+	a.events.handle(linebot.EventTypeMessage, a.handleMessage)
+	a.events.handle(linebot.EventTypeFollow, a.onWelcome)
+	a.events.handle(linebot.EventTypeJoin, a.onWelcome)
+	a.events.handle(linebot.EventTypeMemberJoined, a.onMemberJoined)
+	a.events.handle(linebot.EventTypeMemberLeft, a.onMemberLeft)
+
+	a.events.handlePostback(browseAction, a.browsePostback)
+}
+
+// handleMessage replies to the text messages with the commands or memes.
+func (a *App) handleMessage(ctx context.Context, event *linebot.Event) {
+	textMessage, ok := event.Message.(*linebot.TextMessage)
+	if !ok {
+		return
+	}
+
+	if !a.handleCommand(ctx, event, textMessage.Text) {
+		a.replyWithMeme(ctx, event.ReplyToken, event.Source, textMessage.Text)
+	}
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestEventRouter(t *testing.T) {
+	// Stub.
+	r := newEventRouter(slog.Default())
+	got := []string{}
+	r.handle(linebot.EventTypeFollow, func(ctx context.Context, event *linebot.Event) {
+		got = append(got, "follow")
+	})
+	r.handlePostback("vote", func(ctx context.Context, event *linebot.Event, params url.Values) {
+		got = append(got, "vote "+params.Get("meme"))
+	})
+
+	postback := func(data string) *linebot.Event {
+		return &linebot.Event{Type: linebot.EventTypePostback, Postback: &linebot.Postback{Data: data}}
+	}
+
+	// Testcases.
+	tests := []struct {
+		testName string
+		event    *linebot.Event
+		want     []string
+	}{
+		{"Event type", &linebot.Event{Type: linebot.EventTypeFollow}, []string{"follow"}},
+		{"No handler", &linebot.Event{Type: linebot.EventTypeUnfollow}, []string{}},
+		{"Postback", postback(postbackData("vote", url.Values{"meme": {"3"}})), []string{"vote 3"}},
+		{"Unknown action", postback("action=unknown"), []string{}},
+		{"No action", postback("meme=3"), []string{}},
+		{"Malformed data", postback("%zz"), []string{}},
+		{"No postback", &linebot.Event{Type: linebot.EventTypePostback}, []string{}},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			got = []string{}
+
+			// When.
+			r.route(context.Background(), tc.event)
+
+			// Want.
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("want %v; got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParsePostback(t *testing.T) {
+	// When.
+	action, params, err := parsePostback(postbackData("browse", url.Values{"cursor": {"9"}}))
+
+	// Want.
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action != "browse" || !reflect.DeepEqual(params, url.Values{"cursor": {"9"}}) {
+		t.Errorf("want browse map[cursor:[9]]; got %v %v", action, params)
+	}
+}
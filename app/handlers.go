@@ -2,28 +2,27 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/YuChaoGithub/meme-linebot/app/models"
 	"github.com/line/line-bot-sdk-go/linebot"
 )
 
 const (
 	homeTemplateFilePath = "./ui/html/home.html"
-	greetingMemeName     = "bonjour.jpg"
-	farewellMemeName     = "adios.jpg"
+	defaultStatsLimit    = 10
+	defaultSearchLimit   = 10
+	maxReplyMessages     = 5 // LINE allows at most 5 messages in a reply.
+	// maxScannedKeywords is the most keyword occurrences looked up in a message, matched or not.
+	maxScannedKeywords = 2 * maxReplyMessages
 )
 
-var validSuffixes = []string{".jpg", ".png", ".gif", ".jpeg"}
-var punctuations = map[rune]struct{}{
-	'?': {}, '!': {}, '.': {}, ',': {}, '\'': {}, ';': {}, ':': {}, '-': {}, '(': {}, ')': {}, '"': {},
-	'。': {}, '，': {}, '！': {}, '？': {}, '、': {}, '：': {}, '；': {}, '）': {}, '（': {},
-	'‘': {}, '’': {}, '“': {}, '”': {},
-}
-
 // homepageHandler renders the home page listing all available memes.
 func (a *App) homepageHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
@@ -33,7 +32,7 @@ func (a *App) homepageHandler(w http.ResponseWriter, r *http.Request) {
 	t, ok := a.pageTemplates["home.html"]
 	if !ok {
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("Error getting the page template.")
+		a.log(r.Context()).Error("getting the page template", "template", "home.html")
 		return
 	}
 
@@ -42,14 +41,14 @@ func (a *App) homepageHandler(w http.ResponseWriter, r *http.Request) {
 	memes, err := a.memeModel.GetAll()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("Error fetching memes from the database.")
+		a.log(r.Context()).Error("fetching memes from the database", "error", err)
 		return
 	}
 
 	err = t.Execute(buf, memes)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("Error executing the page template.")
+		a.log(r.Context()).Error("executing the page template", "error", err)
 	}
 
 	buf.WriteTo(w)
@@ -57,28 +56,37 @@ func (a *App) homepageHandler(w http.ResponseWriter, r *http.Request) {
 
 // callbackHandler handles line message callbacks.
 func (a *App) callbackHandler(w http.ResponseWriter, r *http.Request) {
+	// Keep the body to read the delivery information of the events, which the SDK does not parse.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
 	// Check if it is a valid line callback request.
 	events, err := a.bot.ParseRequest(r)
 	if err != nil {
 		if err == linebot.ErrInvalidSignature {
+			a.log(r.Context()).Warn("invalid webhook signature")
 			w.WriteHeader(http.StatusBadRequest)
 		} else {
+			a.log(r.Context()).Error("parsing the webhook request", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 		}
 		return
 	}
 
-	// Loop through events and reply to relevant ones.
-	for _, event := range events {
-		if event.Type == linebot.EventTypeMessage {
-			if textMessage, ok := event.Message.(*linebot.TextMessage); ok {
-				a.replyWithMeme(event.ReplyToken, textMessage.Text)
-			}
-		} else if event.Type == linebot.EventTypeMemberJoined {
-			a.replyWithMeme(event.ReplyToken, greetingMemeName)
-		} else if event.Type == linebot.EventTypeMemberLeft {
-			a.replyWithMeme(event.ReplyToken, farewellMemeName)
+	// Queue the events to be processed in the background, so that LINE does not time out
+	// and redeliver them.
+	deliveries := webhookDeliveries(body, len(events))
+	for i, event := range events {
+		job := webhookJob{event: event, logger: a.log(r.Context())}
+		if deliveries != nil {
+			job.delivery = deliveries[i]
 		}
+
+		a.workers.submit(r.Context(), job)
 	}
 }
 
@@ -91,16 +99,15 @@ func (a *App) addMeme(w http.ResponseWriter, r *http.Request) {
 	// Retrieve the request body.
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		log.Println(err)
+		a.log(r.Context()).Error("reading the request body", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	// Unmarshal json.
 	req := struct {
-		Admin string `json:"admin"`
-		Name  string `json:"name"`
-		Link  string `json:"link"`
+		Name string `json:"name"`
+		Link string `json:"link"`
 	}{}
 	err = json.Unmarshal(body, &req)
 	if err != nil {
@@ -108,23 +115,29 @@ func (a *App) addMeme(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the admin key is matched.
-	if req.Admin != a.adminSecret {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
 	// Insert to the database.
 	err = a.memeModel.Insert(req.Name, req.Link)
-	if err != nil {
+	if errors.Is(err, models.ErrTrashed) {
+		http.Error(w, "the meme is in the trash; restore or purge it first", http.StatusConflict)
+		return
+	} else if err != nil {
 		w.WriteHeader(http.StatusConflict)
+		return
 	}
 
+	// The name may have become an alias of an existing meme.
+	newValue := ""
+	if id, err := a.memeModel.GetID(req.Name); err == nil {
+		newValue = a.snapshot(r.Context(), id)
+	}
+	a.audit(r, auditMemeAdd, req.Name, "", newValue)
+
 	// Success.
 	w.WriteHeader(http.StatusCreated)
 }
 
-// deleteMeme is used by the admin to delete a meme entry.
+// deleteMeme is used by the admin to move a meme entry along with all of its aliases to the
+// trash, from which it can be restored until it is purged.
 func (a *App) deleteMeme(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		return
@@ -133,15 +146,59 @@ func (a *App) deleteMeme(w http.ResponseWriter, r *http.Request) {
 	// Retrieve the request body.
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		log.Println(err)
+		a.log(r.Context()).Error("reading the request body", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Unmarshal json.
+	req := struct {
+		Name string `json:"name"`
+	}{}
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Find the meme.
+	id, err := a.memeModel.GetID(req.Name)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	oldValue := a.snapshot(r.Context(), id)
+
+	// Delete from the database.
+	err = a.memeModel.Delete(req.Name)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	a.audit(r, auditMemeDelete, req.Name, oldValue, "")
+
+	// Success.
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addAlias is used by the admin to add an alias name to the meme which an existing name refers to.
+func (a *App) addAlias(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		return
+	}
+
+	// Retrieve the request body.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		a.log(r.Context()).Error("reading the request body", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	// Unmarshal json.
 	req := struct {
-		Admin string `json:"admin"`
 		Name  string `json:"name"`
+		Alias string `json:"alias"`
 	}{}
 	err = json.Unmarshal(body, &req)
 	if err != nil {
@@ -149,63 +206,372 @@ func (a *App) deleteMeme(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the admin key is matched.
-	if req.Admin != a.adminSecret {
-		w.WriteHeader(http.StatusUnauthorized)
+	// Find the meme.
+	id, err := a.memeModel.GetID(req.Name)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
+	oldValue := a.snapshot(r.Context(), id)
+
 	// Insert to the database.
-	err = a.memeModel.Delete(req.Name)
+	err = a.memeModel.AddAlias(id, req.Alias)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	a.audit(r, auditAliasAdd, req.Alias, oldValue, a.snapshot(r.Context(), id))
+
+	// Success.
+	w.WriteHeader(http.StatusCreated)
+}
+
+// removeAlias is used by the admin to remove an alias name. The last alias of a meme cannot be
+// removed; use deleteMeme instead.
+func (a *App) removeAlias(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		return
+	}
+
+	// Retrieve the request body.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		a.log(r.Context()).Error("reading the request body", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Unmarshal json.
+	req := struct {
+		Name string `json:"name"`
+	}{}
+	err = json.Unmarshal(body, &req)
 	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Find the meme.
+	id, err := a.memeModel.GetID(req.Name)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	oldValue := a.snapshot(r.Context(), id)
+
+	// Remove from the database.
+	err = a.memeModel.RemoveAlias(req.Name)
+	if errors.Is(err, models.ErrLastAlias) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	} else if err != nil {
 		w.WriteHeader(http.StatusNotFound)
+		return
 	}
+	a.audit(r, auditAliasRemove, req.Name, oldValue, a.snapshot(r.Context(), id))
 
 	// Success.
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// replyWithMeme is a helper function which replies to the event (with the replyToken) with
-// a meme named memeName. It does nothing if no such meme exists.
-func (a *App) replyWithMeme(replyToken string, memeName string) {
-	formattedName := strings.TrimSpace(strings.ToLower(memeName))
-
-	// Check if the format is correct, that is, it has a trailing .jpg, .png, etc.
-	isValid := false
-	for _, val := range validSuffixes {
-		if strings.HasSuffix(formattedName, val) {
-			formattedName = strings.TrimSuffix(formattedName, val)
-			isValid = true
-			break
-		}
+// getStats is used by the admin to get the meme usage statistics: the most sent memes in the
+// last given days (all time if zero), optionally within a chat, and the memes never sent.
+func (a *App) getStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		return
 	}
-	if !isValid {
+
+	// Retrieve the request body.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		a.log(r.Context()).Error("reading the request body", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	// Get rid of punctuations.
-	cleanedName := []rune{}
-	for _, val := range formattedName {
-		if _, ok := punctuations[val]; !ok {
-			cleanedName = append(cleanedName, val)
-		}
+	// Unmarshal json.
+	req := struct {
+		Days   int    `json:"days"`
+		Limit  int    `json:"limit"`
+		Source string `json:"source"`
+	}{}
+	err = json.Unmarshal(body, &req)
+	if err != nil || req.Days < 0 || req.Limit < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if req.Limit == 0 {
+		req.Limit = defaultStatsLimit
+	}
+
+	since := time.Time{}
+	if req.Days > 0 {
+		since = time.Now().AddDate(0, 0, -req.Days)
+	}
+
+	// Query the database.
+	res := struct {
+		Top    []models.MemeStat `json:"top"`
+		Unused []models.MemeStat `json:"unused"`
+	}{}
+	if req.Source == "" {
+		res.Top, err = a.memeModel.TopMemes(since, req.Limit)
+	} else {
+		res.Top, err = a.memeModel.TopMemesBySource(a.hashSourceID(req.Source), since, req.Limit)
+	}
+	if err != nil {
+		a.log(r.Context()).Error("fetching the top memes", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res.Unused, err = a.memeModel.UnusedMemes()
+	if err != nil {
+		a.log(r.Context()).Error("fetching the unused memes", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Success.
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// searchMemes is used by the admin to see the closest matching names of a query along with
+// their similarity scores, e.g. to debug a bad fuzzy match.
+func (a *App) searchMemes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		return
 	}
 
-	// Get the meme with exact matching name from the database.
-	memeURL, err := a.memeModel.Get(string(cleanedName))
+	// Retrieve the request body.
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		// Get the meme with closest matching name from the database.
-		memeURL, err = a.memeModel.GetFuzzy(string(cleanedName))
+		a.log(r.Context()).Error("reading the request body", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Unmarshal json.
+	req := struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}{}
+	err = json.Unmarshal(body, &req)
+	if err != nil || req.Query == "" || req.Limit < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if req.Limit == 0 {
+		req.Limit = defaultSearchLimit
+	}
+
+	// Query the database.
+	res := struct {
+		Results []models.ScoredMeme `json:"results"`
+	}{}
+	res.Results, err = a.memeModel.Search(strings.ToLower(req.Query), req.Limit)
+	if err != nil {
+		a.log(r.Context()).Error("searching memes", "query", req.Query, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Success.
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// memeMatch is a meme found in a message.
+type memeMatch struct {
+	keyword string // The keyword received from the user, with punctuations stripped.
+	url     string
+	fuzzy   bool
+	nearest []string // The closest names to the keyword if it is a fuzzy match.
+}
+
+// findMemes returns the memes mentioned as "<keyword>.<ext>" anywhere in the message, in the
+// order they appear, without duplicates and at most maxReplyMessages of them. Only the first
+// maxScannedKeywords occurrences are looked up. For each occurrence, the longest candidate
+// keyword with an exact match wins; otherwise the meme with the closest matching name to the
+// whole candidate text is used if it is similar enough. If it is not, the closest names are
+// returned as suggestions instead, at most maxSuggestions of them.
+func (a *App) findMemes(ctx context.Context, message string) ([]memeMatch, []string) {
+	res, suggestions := []memeMatch{}, []string{}
+	seen, suggested := map[string]struct{}{}, map[string]struct{}{}
+
+	for i, candidates := range scanKeywords(message) {
+		if len(res) == maxReplyMessages || i == maxScannedKeywords {
+			break
+		}
+
+		// Get the meme with exact matching name from the database.
+		urls, err := a.memeModel.GetMany(candidates)
 		if err != nil {
-			// No match.
-			return
+			a.log(ctx).Error("fetching memes by name", "error", err)
+			return res, suggestions
+		}
+
+		match := memeMatch{}
+		for _, candidate := range candidates {
+			if url, ok := urls[candidate]; ok {
+				match = memeMatch{keyword: candidate, url: url}
+				a.metrics.countMatch(exactMatch)
+				break
+			}
+		}
+
+		if match.url == "" {
+			// Get the memes with closest matching names from the database.
+			results, err := a.memeModel.Search(candidates[0], maxQuickReplyItems)
+			if err != nil {
+				a.log(ctx).Error("searching memes", "query", candidates[0], "error", err)
+				return res, suggestions
+			}
+
+			if len(results) == 0 {
+				// No match.
+				a.metrics.countMatch(noMatch)
+				continue
+			}
+
+			if results[0].Similarity < confidentSimilarity {
+				// Not confident enough. Let the user pick one.
+				a.metrics.countMatch(suggestedMatch)
+				if len(results) > maxSuggestionsPerKeyword {
+					results = results[:maxSuggestionsPerKeyword]
+				}
+				for _, result := range results {
+					if _, ok := suggested[result.Name]; !ok && len(suggestions) < maxSuggestions {
+						suggested[result.Name] = struct{}{}
+						suggestions = append(suggestions, result.Name)
+					}
+				}
+				continue
+			}
+
+			match = memeMatch{keyword: candidates[0], url: results[0].Link, fuzzy: true}
+			a.metrics.countMatch(fuzzyMatch)
+			for _, result := range results {
+				match.nearest = append(match.nearest, result.Name)
+			}
 		}
+
+		if _, ok := seen[match.url]; ok {
+			continue
+		}
+		seen[match.url] = struct{}{}
+
+		res = append(res, match)
+	}
+
+	return res, suggestions
+}
+
+// allowReply tells whether the rate limits allow a meme reply to the source. If not, it replies
+// with "slow down" once per window if enabled.
+func (a *App) allowReply(ctx context.Context, replyToken string, source *linebot.EventSource) bool {
+	ok, slowDown := a.limiter.allow(source)
+	if slowDown {
+		a.reply(ctx, replyToken, linebot.NewTextMessage(slowDownText))
+	}
+
+	return ok
+}
+
+// findImplicitMeme returns the meme whose name exactly equals the whole message (with
+// punctuations stripped), if the chat has turned on the implicit keyword mode.
+func (a *App) findImplicitMeme(ctx context.Context, source *linebot.EventSource, message string) []memeMatch {
+	keyword := cleanKeyword([]rune(strings.ToLower(message)))
+	if keyword == "" || len([]rune(keyword)) > maxKeywordLength {
+		return nil
+	}
+
+	// Exact match only. A fuzzy match of an ordinary message would be too noisy. Most messages
+	// are not a meme name, so the settings are only read for those which are.
+	url, err := a.memeModel.Get(keyword)
+	if err != nil {
+		return nil
 	}
 
-	// Reply with the meme image.
-	_, err = a.bot.ReplyMessage(replyToken, linebot.NewImageMessage(memeURL, memeURL)).Do()
+	settings, err := a.settings.GetChatSettings(chatKey(source))
 	if err != nil {
-		log.Printf("Error sending reply message with the meme <%v>, link <%v>.\n", memeName, memeURL)
-		log.Println(err)
+		a.log(ctx).Error("fetching the chat settings", "error", err)
+		return nil
+	}
+
+	if !settings.ImplicitTrigger {
+		return nil
+	}
+
+	return []memeMatch{{keyword: keyword, url: url}}
+}
+
+// replyWithMeme is a helper function which replies to the event (with the replyToken) with
+// the memes mentioned in the message, and suggests the closest names of the keywords without a
+// confident match. It does nothing if there is neither, or if the rate limits are hit.
+// The memes sent in the group within its cooldown are skipped.
+// Successful replies are recorded for the usage statistics.
+func (a *App) replyWithMeme(ctx context.Context, replyToken string, source *linebot.EventSource, message string) {
+	// Stop the users and chats sending too many memes before looking them up, unless the
+	// message can only be an implicit keyword.
+	explicit := len(scanKeywords(message)) > 0
+	if explicit && !a.allowReply(ctx, replyToken, source) {
+		return
+	}
+
+	matches, suggestions := a.findMemes(ctx, message)
+	if len(matches) == 0 && len(suggestions) == 0 {
+		matches = a.findImplicitMeme(ctx, source, message)
+	}
+	if len(matches) == 0 && len(suggestions) == 0 {
+		return
+	}
+
+	if !explicit && !a.allowReply(ctx, replyToken, source) {
+		return
+	}
+
+	// Skip the memes just sent in the group. The cooldown starts once the reply is sent.
+	chatID, cooldown := a.cooldownOf(ctx, source)
+	matches = a.coolDown(chatID, cooldown, matches)
+	if len(matches) == 0 && len(suggestions) == 0 {
+		return
+	}
+
+	// Reply with the meme images and the suggestions in one message.
+	messages := []linebot.SendingMessage{}
+	for _, match := range matches {
+		messages = append(messages, linebot.NewImageMessage(match.url, match.url))
+	}
+	if len(suggestions) > 0 && len(messages) < maxReplyMessages {
+		messages = append(messages, suggestionMessage(suggestions))
+	} else if names := nearestNames(matches); len(names) > 0 && a.quickRepliesEnabled(ctx, source) {
+		// Quick replies are only shown with the last message, which is an image here.
+		last := len(messages) - 1
+		messages[last] = messages[last].(*linebot.ImageMessage).WithQuickReplies(quickReplies(keywordsOf(names)))
+	}
+
+	_, err := a.bot.ReplyMessage(replyToken, messages...).Do()
+	if err != nil {
+		a.log(ctx).Error("sending the reply message with the memes", "message", message, "error", err)
+		return
+	}
+	a.startCooldown(chatID, cooldown, matches)
+
+	// Record the usages.
+	sourceType, sourceID := a.sourceOf(source)
+	for _, match := range matches {
+		a.usages.record(models.Usage{
+			Link:       match.url,
+			Keyword:    match.keyword,
+			Fuzzy:      match.fuzzy,
+			SourceType: sourceType,
+			SourceID:   sourceID,
+			Time:       time.Now(),
+		})
 	}
 }
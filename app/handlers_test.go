@@ -0,0 +1,308 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestHomepageHandler(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+
+	// When.
+	rr := httptest.NewRecorder()
+	a.homepageHandler(rr, httptest.NewRequest("GET", "/", nil))
+
+	// Want.
+	if rr.Code != http.StatusOK {
+		t.Errorf("want %v; got %v", http.StatusOK, rr.Code)
+	}
+
+	if !strings.Contains(rr.Body.String(), "我就爛.jpg") {
+		t.Errorf("want body to contain %q", "我就爛.jpg")
+	}
+}
+
+func TestAddMeme(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName   string
+		secret     string
+		body       string
+		wantStatus int
+	}{
+		{"Success", "secret", `{"name": "ah", "link": "txt.png"}`, http.StatusCreated},
+		{"Existing Entry", "secret", `{"name": "我就爛", "link": "t9WaxTw.png"}`, http.StatusConflict},
+		{"Wrong secret", "guess", `{"name": "ah", "link": "txt.png"}`, http.StatusUnauthorized},
+		{"Malformed body", "secret", `{"name":`, http.StatusBadRequest},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			a := newTestApp(t)
+
+			// When.
+			rr := httptest.NewRecorder()
+			a.routes().ServeHTTP(rr, newAdminRequest("/add", tc.secret, tc.body))
+
+			// Want.
+			if rr.Code != tc.wantStatus {
+				t.Errorf("want %v; got %v", tc.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestDeleteMeme(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName   string
+		secret     string
+		body       string
+		wantStatus int
+	}{
+		{"Success", "secret", `{"name": "我就爛"}`, http.StatusNoContent},
+		{"Wrong secret", "guess", `{"name": "我就爛"}`, http.StatusUnauthorized},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			a := newTestApp(t)
+
+			// When.
+			rr := httptest.NewRecorder()
+			a.routes().ServeHTTP(rr, newAdminRequest("/delete", tc.secret, tc.body))
+
+			// Want.
+			if rr.Code != tc.wantStatus {
+				t.Errorf("want %v; got %v", tc.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestAddAlias(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName   string
+		secret     string
+		body       string
+		wantStatus int
+	}{
+		{"Success", "secret", `{"name": "我就爛", "alias": "爛"}`, http.StatusCreated},
+		{"Existing alias", "secret", `{"name": "我就爛", "alias": "我就爛"}`, http.StatusConflict},
+		{"Meme doesn't exist", "secret", `{"name": "ah", "alias": "爛"}`, http.StatusNotFound},
+		{"Wrong secret", "guess", `{"name": "我就爛", "alias": "爛"}`, http.StatusUnauthorized},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			a := newTestApp(t)
+
+			// When.
+			rr := httptest.NewRecorder()
+			a.routes().ServeHTTP(rr, newAdminRequest("/alias/add", tc.secret, tc.body))
+
+			// Want.
+			if rr.Code != tc.wantStatus {
+				t.Errorf("want %v; got %v", tc.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRemoveAlias(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName   string
+		secret     string
+		body       string
+		wantStatus int
+	}{
+		{"Last alias", "secret", `{"name": "我就爛"}`, http.StatusConflict},
+		{"Doesn't exist", "secret", `{"name": "ah"}`, http.StatusNotFound},
+		{"Wrong secret", "guess", `{"name": "我就爛"}`, http.StatusUnauthorized},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			a := newTestApp(t)
+
+			// When.
+			rr := httptest.NewRecorder()
+			a.routes().ServeHTTP(rr, newAdminRequest("/alias/remove", tc.secret, tc.body))
+
+			// Want.
+			if rr.Code != tc.wantStatus {
+				t.Errorf("want %v; got %v", tc.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestFindMemes(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+	for _, name := range []string{"adios", "bonjour", "honest work", "a", "b"} {
+		if err := a.memeModel.Insert(name, name+".png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Testcases.
+	tests := []struct {
+		testName        string
+		message         string
+		wantKeywords    []string
+		wantFuzzy       []bool
+		wantSuggestions []string
+	}{
+		{"Exact", "我就爛.jpg", []string{"我就爛"}, []bool{false}, []string{}},
+		{"In a sentence", "哈哈我就爛.jpg haha", []string{"我就爛"}, []bool{false}, []string{}},
+		{"Fuzzy", "bonjer.jpg", []string{"bonjer"}, []bool{true}, []string{}},
+		{"Did you mean", "就爛.jpg", []string{}, []bool{}, []string{"我就爛"}},
+		{"Matches and suggestions", "adios.jpg 就爛.jpg", []string{"adios"}, []bool{false}, []string{"我就爛"}},
+		{"Several", "adios.jpg and honest work.png!", []string{"adios", "honest work"}, []bool{false, false}, []string{}},
+		{"Duplicates", "adios.jpg adios.jpg", []string{"adios"}, []bool{false}, []string{}},
+		{"At most five", "adios.jpg bonjour.jpg a.jpg b.jpg honest work.jpg 我就爛.jpg", []string{"adios", "bonjour", "a", "b", "honest work"}, []bool{false, false, false, false, false}, []string{}},
+		{"No match", "xyz.jpg", []string{}, []bool{}, []string{}},
+		{"At most ten occurrences", "x0.jpg x1.jpg x2.jpg x3.jpg x4.jpg x5.jpg x6.jpg x7.jpg x8.jpg x9.jpg adios.jpg", []string{}, []bool{}, []string{}},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			matches, suggestions := a.findMemes(context.Background(), tc.message)
+
+			// Want.
+			keywords, fuzzy := []string{}, []bool{}
+			for _, m := range matches {
+				keywords = append(keywords, m.keyword)
+				fuzzy = append(fuzzy, m.fuzzy)
+			}
+
+			if !reflect.DeepEqual(keywords, tc.wantKeywords) || !reflect.DeepEqual(fuzzy, tc.wantFuzzy) {
+				t.Errorf("want %v %v; got %v %v", tc.wantKeywords, tc.wantFuzzy, keywords, fuzzy)
+			}
+
+			if !reflect.DeepEqual(suggestions, tc.wantSuggestions) {
+				t.Errorf("want suggestions %v; got %v", tc.wantSuggestions, suggestions)
+			}
+		})
+	}
+}
+
+func TestSearchMemes(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+
+	// Testcases.
+	tests := []struct {
+		testName  string
+		secret    string
+		body      string
+		wantCode  int
+		wantNames []string
+	}{
+		{"Valid", "secret", `{"query": "就爛"}`, http.StatusOK, []string{"我就爛"}},
+		{"No results", "secret", `{"query": "xyz"}`, http.StatusOK, []string{}},
+		{"Empty query", "secret", `{"query": ""}`, http.StatusBadRequest, nil},
+		{"Wrong secret", "guess", `{"query": "就爛"}`, http.StatusUnauthorized, nil},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			rr := httptest.NewRecorder()
+			a.routes().ServeHTTP(rr, newAdminRequest("/search", tc.secret, tc.body))
+
+			// Want.
+			if rr.Code != tc.wantCode {
+				t.Fatalf("want %v; got %v", tc.wantCode, rr.Code)
+			}
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+
+			res := struct {
+				Results []models.ScoredMeme `json:"results"`
+			}{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+
+			names := []string{}
+			for _, result := range res.Results {
+				names = append(names, result.Name)
+				if result.Similarity <= 0 || result.Similarity > 1 {
+					t.Errorf("want similarity in (0, 1]; got %v", result.Similarity)
+				}
+			}
+			if !reflect.DeepEqual(names, tc.wantNames) {
+				t.Errorf("want %v; got %v", tc.wantNames, names)
+			}
+		})
+	}
+}
+
+func TestFindImplicitMeme(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+	timed := newTimedStore(a.memeModel.(store), a.metrics)
+	a.memeModel, a.settings = timed, timed
+	on := &linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: "on"}
+	off := &linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: "off"}
+
+	if err := a.settings.SaveChatSettings(chatKey(on), models.ChatSettings{ImplicitTrigger: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Testcases.
+	tests := []struct {
+		testName  string
+		source    *linebot.EventSource
+		message   string
+		wantMatch bool
+	}{
+		{"Exact", on, "我就爛", true},
+		{"Punctuations", on, " 我就爛！", true},
+		{"Never fuzzy", on, "就爛", false},
+		{"Turned off", off, "我就爛", false},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			matches := a.findImplicitMeme(context.Background(), tc.source, tc.message)
+
+			// Want.
+			if (len(matches) == 1) != tc.wantMatch {
+				t.Errorf("want match %v; got %v", tc.wantMatch, matches)
+			}
+		})
+	}
+
+	// Want. The settings are not read for the message which is not a meme name.
+	if want := `memebot_db_query_duration_seconds_count{method="GetChatSettings"} 3`; !strings.Contains(scrape(t, a.routes()), want) {
+		t.Errorf("want metrics to contain %q", want)
+	}
+}
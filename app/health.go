@@ -0,0 +1,143 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// pingTimeout is how long the readiness check waits for the database.
+const pingTimeout = 2 * time.Second
+
+var (
+	errStorageNotReady = errors.New("the storage is not connected and migrated yet")
+	errNoLineClient    = errors.New("the LINE client is not initialized")
+	errNoTemplates     = errors.New("the page templates are not loaded")
+)
+
+// healthCheck is the result of a readiness check.
+type healthCheck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func newHealthCheck(err error) healthCheck {
+	if err != nil {
+		return healthCheck{Error: err.Error()}
+	}
+
+	return healthCheck{OK: true}
+}
+
+// readiness is the body of the readiness response.
+type readiness struct {
+	Ready  bool                   `json:"ready"`
+	Checks map[string]healthCheck `json:"checks"`
+}
+
+// buildVersion is the body of the version response.
+type buildVersion struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified"`
+}
+
+// healthHandler responds 200 as long as the process can serve requests. Anything else is
+// checked by readyHandler, so that an instance is not restarted when the database is down.
+func (a *App) healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// readyHandler responds 200 if the app can serve the requests, and 503 otherwise, with the
+// result of each check.
+func (a *App) readyHandler(w http.ResponseWriter, r *http.Request) {
+	res := readiness{
+		Ready: true,
+		Checks: map[string]healthCheck{
+			"database":  newHealthCheck(a.checkDB(r.Context())),
+			"line":      newHealthCheck(a.checkLineClient()),
+			"templates": newHealthCheck(a.checkTemplates()),
+		},
+	}
+	for _, check := range res.Checks {
+		res.Ready = res.Ready && check.OK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !res.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// versionHandler responds with the build information of the binary.
+func (a *App) versionHandler(w http.ResponseWriter, r *http.Request) {
+	res := buildVersion{Version: "unknown"}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		res.Version = info.Main.Version
+		res.GoVersion = info.GoVersion
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				res.Revision = s.Value
+			case "vcs.time":
+				res.Time = s.Value
+			case "vcs.modified":
+				res.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// checkDB tells whether the storage is prepared, and pings the database if any.
+func (a *App) checkDB(ctx context.Context) error {
+	if !a.storageReady.Load() {
+		return errStorageNotReady
+	}
+	if a.db == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	return a.db.PingContext(ctx)
+}
+
+func (a *App) checkLineClient() error {
+	if a.bot == nil {
+		return errNoLineClient
+	}
+
+	return nil
+}
+
+func (a *App) checkTemplates() error {
+	if _, ok := a.pageTemplates["home.html"]; !ok {
+		return errNoTemplates
+	}
+
+	return nil
+}
+
+// requireStorage responds 503 instead of calling h until the storage is ready.
+func (a *App) requireStorage(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.storageReady.Load() {
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "the storage is not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		h(w, r)
+	}
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestReadyHandler(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName  string
+		ready     bool
+		bot       *linebot.Client
+		templates templateCache
+		wantCode  int
+		wantFail  []string
+	}{
+		{"Ready", true, &linebot.Client{}, nil, http.StatusOK, nil},
+		{"Storage not ready", false, &linebot.Client{}, nil, http.StatusServiceUnavailable, []string{"database"}},
+		{"No LINE client", true, nil, nil, http.StatusServiceUnavailable, []string{"line"}},
+		{"No templates", true, &linebot.Client{}, templateCache{}, http.StatusServiceUnavailable, []string{"templates"}},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			a := newTestApp(t)
+			a.storageReady.Store(tc.ready)
+			a.bot = tc.bot
+			if tc.templates != nil {
+				a.pageTemplates = tc.templates
+			}
+
+			// When.
+			rr := httptest.NewRecorder()
+			a.readyHandler(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+			// Want.
+			if rr.Code != tc.wantCode {
+				t.Errorf("want %v; got %v", tc.wantCode, rr.Code)
+			}
+
+			res := readiness{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(res.Checks) != 3 {
+				t.Errorf("want 3 checks; got %v", res.Checks)
+			}
+
+			failed := map[string]bool{}
+			for _, name := range tc.wantFail {
+				failed[name] = true
+			}
+			for name, check := range res.Checks {
+				if check.OK == failed[name] {
+					t.Errorf("check %v: want ok %v; got %+v", name, !failed[name], check)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthAndVersion(t *testing.T) {
+	// Stub. The app is not ready, which does not matter.
+	a := newTestApp(t)
+	a.storageReady.Store(false)
+	handler := a.routes()
+
+	for _, path := range []string{"/healthz", "/version"} {
+		// When.
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+
+		// Want.
+		if rr.Code != http.StatusOK {
+			t.Errorf("%v: want %v; got %v", path, http.StatusOK, rr.Code)
+		}
+
+		if !json.Valid(rr.Body.Bytes()) {
+			t.Errorf("%v: want a JSON body; got %q", path, rr.Body.String())
+		}
+	}
+
+	// When.
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/version", nil))
+
+	// Want.
+	res := map[string]interface{}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if goVersion, _ := res["go_version"].(string); !strings.HasPrefix(goVersion, "go") {
+		t.Errorf("want go_version; got %v", res)
+	}
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/config"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+const (
+	requestIDHeader    = "X-Request-Id"
+	maxRequestIDLength = 64
+)
+
+// Routes polled by the orchestrator and Prometheus, whose requests are logged at debug level.
+var quietRoutes = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// loggerKey is the context key of the logger with the attributes of the request or event.
+type loggerKey struct{}
+
+// newLogger returns a logger writing to w in the format ("json" or "text", i.e. logfmt) at
+// the level ("debug", "info", "warn" or "error") of the config.
+func newLogger(config config.LogConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", config.Level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	switch config.Format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q", config.Format)
+	}
+}
+
+// withLogger returns a copy of ctx carrying the logger.
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the logger carried by ctx, or fallback.
+func loggerFrom(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return fallback
+}
+
+// log returns the logger carried by ctx, or the logger of the app.
+func (a *App) log(ctx context.Context) *slog.Logger {
+	return loggerFrom(ctx, a.logger)
+}
+
+// logRequests assigns a request ID to each request of the route, and logs the request when it
+// is done. The request ID is taken from the X-Request-Id header if any, and is attached to
+// every log line of the request.
+func (a *App) logRequests(route string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		logger := a.logger.With("request_id", id)
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		h(sw, r.WithContext(withLogger(r.Context(), logger)))
+
+		level := slog.LevelInfo
+		switch {
+		case sw.status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case quietRoutes[route]:
+			level = slog.LevelDebug
+		}
+		logger.Log(r.Context(), level, "request",
+			"method", r.Method,
+			"route", route,
+			"status", sw.status,
+			"latency", time.Since(start))
+	}
+}
+
+// validRequestID tells whether the request ID from a client is safe to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	return strings.IndexFunc(id, func(r rune) bool { return r <= ' ' || r > '~' }) < 0
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// eventLogger returns the logger with the attributes of the webhook event: its ID, type and
+// source, whose ID is hashed unless the config says otherwise.
+func (a *App) eventLogger(logger *slog.Logger, event *linebot.Event, delivery webhookDelivery) *slog.Logger {
+	attrs := []any{"event_type", event.Type}
+	if delivery.WebhookEventID != "" {
+		attrs = append(attrs, "event_id", delivery.WebhookEventID)
+	}
+	if delivery.DeliveryContext.IsRedelivery {
+		attrs = append(attrs, "redelivery", true)
+	}
+
+	if event.Source != nil {
+		sourceType, sourceID := a.sourceOf(event.Source)
+		if !a.hashLogIDs {
+			// The group or room, or else the user, the same as sourceOf.
+			sourceID = event.Source.GroupID + event.Source.RoomID
+			if sourceID == "" {
+				sourceID = event.Source.UserID
+			}
+		}
+		attrs = append(attrs, "source_type", sourceType, "source_id", sourceID)
+	}
+
+	return logger.With(attrs...)
+}
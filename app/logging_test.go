@@ -0,0 +1,190 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/YuChaoGithub/meme-linebot/config"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// logLines returns the JSON log lines in the buffer.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	lines := []map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		m := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		lines = append(lines, m)
+	}
+
+	return lines
+}
+
+func TestNewLogger(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName string
+		config   config.LogConfig
+		wantErr  bool
+	}{
+		{"JSON", config.LogConfig{Level: "info", Format: "json"}, false},
+		{"Text", config.LogConfig{Level: "DEBUG", Format: "text"}, false},
+		{"Invalid level", config.LogConfig{Level: "loud", Format: "json"}, true},
+		{"Invalid format", config.LogConfig{Level: "info", Format: "xml"}, true},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			_, err := newLogger(tc.config, &bytes.Buffer{})
+
+			// Want.
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("want error %v; got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName  string
+		requestID string
+		wantID    string // Empty for a generated one.
+	}{
+		{"Client request ID", "abc-123", "abc-123"},
+		{"No request ID", "", ""},
+		{"Invalid request ID", "bad\nid", ""},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub. The handler logs a line of its own.
+			buf := &bytes.Buffer{}
+			a := newTestApp(t)
+			a.logger, _ = newLogger(config.LogConfig{Level: "info", Format: "json"}, buf)
+			h := a.logRequests("/add", func(w http.ResponseWriter, r *http.Request) {
+				a.log(r.Context()).Info("inside")
+				w.WriteHeader(http.StatusTeapot)
+			})
+
+			// When.
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/add", nil)
+			if tc.requestID != "" {
+				req.Header.Set(requestIDHeader, tc.requestID)
+			}
+			h(rr, req)
+
+			// Want.
+			id := rr.Header().Get(requestIDHeader)
+			if tc.wantID != "" && id != tc.wantID {
+				t.Errorf("want request ID %q; got %q", tc.wantID, id)
+			}
+			if tc.wantID == "" && (id == "" || id == tc.requestID) {
+				t.Errorf("want a generated request ID; got %q", id)
+			}
+
+			lines := logLines(t, buf)
+			if len(lines) != 2 {
+				t.Fatalf("want 2 log lines; got %v", lines)
+			}
+			for _, line := range lines {
+				if line["request_id"] != id {
+					t.Errorf("want request_id %q; got %v", id, line)
+				}
+			}
+
+			if got := lines[1]; got["status"] != float64(http.StatusTeapot) || got["route"] != "/add" || got["method"] != "POST" {
+				t.Errorf("want the request logged; got %v", got)
+			}
+		})
+	}
+}
+
+func TestEventLogger(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName     string
+		hashLogIDs   bool
+		wantSourceID func(a *App) string
+	}{
+		{"Hashed", true, func(a *App) string { return a.hashSourceID("G1") }},
+		{"Raw", false, func(a *App) string { return "G1" }},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub. An unknown postback in a group, which the router logs.
+			buf := &bytes.Buffer{}
+			a := newTestApp(t)
+			a.hashLogIDs = tc.hashLogIDs
+			logger, _ := newLogger(config.LogConfig{Level: "info", Format: "json"}, buf)
+			job := webhookJob{
+				event: &linebot.Event{
+					Type:     linebot.EventTypePostback,
+					Source:   &linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: "G1", UserID: "U1"},
+					Postback: &linebot.Postback{Data: "action=unknown"},
+				},
+				delivery: webhookDelivery{WebhookEventID: "e1"},
+				logger:   logger.With("request_id", "r1"),
+			}
+
+			// When.
+			a.processEvent(job)
+
+			// Want.
+			lines := logLines(t, buf)
+			if len(lines) != 1 {
+				t.Fatalf("want 1 log line; got %v", lines)
+			}
+
+			want := map[string]any{
+				"request_id":  "r1",
+				"event_id":    "e1",
+				"event_type":  "postback",
+				"source_type": "group",
+				"source_id":   tc.wantSourceID(a),
+			}
+			for key, value := range want {
+				if lines[0][key] != value {
+					t.Errorf("%v: want %v; got %v", key, value, lines[0][key])
+				}
+			}
+		})
+	}
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+	buf := &bytes.Buffer{}
+	logger, _ := newLogger(config.LogConfig{Level: "info", Format: "json"}, buf)
+
+	// When.
+	got := a.log(withLogger(context.Background(), logger))
+
+	// Want.
+	if got != logger {
+		t.Error("want the logger in the context")
+	}
+
+	if a.log(context.Background()) != a.logger {
+		t.Error("want the logger of the app without one in the context")
+	}
+}
@@ -0,0 +1,204 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "memebot"
+
+// Kinds of the meme matches of a keyword.
+const (
+	exactMatch     = "exact"
+	fuzzyMatch     = "fuzzy"
+	suggestedMatch = "suggested" // Not confident enough, so the closest names are suggested.
+	noMatch        = "none"
+)
+
+// metrics are the Prometheus metrics of the app. They are collected by the middleware and
+// wrappers below, and registered to the app's own registry.
+type metrics struct {
+	registry        *prometheus.Registry
+	httpRequests    *prometheus.CounterVec
+	httpDuration    *prometheus.HistogramVec
+	webhookEvents   *prometheus.CounterVec
+	memeMatches     *prometheus.CounterVec
+	replies         *prometheus.CounterVec
+	lineAPIDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route and status code.",
+		}, []string{"route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route"}),
+		webhookEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "webhook_events_total",
+			Help:      "Webhook events processed by event type.",
+		}, []string{"type"}),
+		memeMatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "meme_matches_total",
+			Help:      "Keywords in the messages by match kind: exact, fuzzy, suggested or none.",
+		}, []string{"kind"}),
+		replies: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "replies_total",
+			Help:      "Reply messages by result: sent or failed.",
+		}, []string{"result"}),
+		lineAPIDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "line_api_duration_seconds",
+			Help:      "LINE Messaging API latency by endpoint.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Meme storage latency by method.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"method"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.webhookEvents,
+		m.memeMatches,
+		m.replies,
+		m.lineAPIDuration,
+		m.queryDuration,
+	)
+
+	return m
+}
+
+// handler serves the metrics in the Prometheus text format.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// registerEventPool exports the counters of the event pool.
+func (m *metrics) registerEventPool(p *eventPool) {
+	m.registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "event_queue_queued_total",
+			Help:      "Webhook events queued for the workers.",
+		}, func() float64 { return float64(p.stats().Queued) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "event_queue_processed_total",
+			Help:      "Webhook events processed by the workers.",
+		}, func() float64 { return float64(p.stats().Processed) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "event_queue_dropped_total",
+			Help:      "Webhook events dropped since the queue was full or closed.",
+		}, func() float64 { return float64(p.stats().Dropped) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "event_queue_length",
+			Help:      "Webhook events waiting in the queue.",
+		}, func() float64 { return float64(p.stats().Pending) }),
+	)
+}
+
+// instrument counts the requests to the route by status code and observes their latency.
+func (m *metrics) instrument(route string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		h(sw, r)
+
+		m.httpRequests.WithLabelValues(route, strconv.Itoa(sw.status)).Inc()
+		m.httpDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// countEvents counts the webhook events by type before handling them.
+func (m *metrics) countEvents(handle func(job webhookJob)) func(job webhookJob) {
+	return func(job webhookJob) {
+		m.webhookEvents.WithLabelValues(string(job.event.Type)).Inc()
+		handle(job)
+	}
+}
+
+// countMatch counts a keyword by how it matched a meme.
+func (m *metrics) countMatch(kind string) {
+	m.memeMatches.WithLabelValues(kind).Inc()
+}
+
+// statusWriter remembers the status code written to the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// lineTransport observes the latency of the LINE Messaging API calls, and counts the replies
+// by result.
+type lineTransport struct {
+	base    http.RoundTripper
+	metrics *metrics
+}
+
+func (t *lineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.base.RoundTrip(req)
+
+	endpoint := lineEndpoint(req.URL.Path)
+	t.metrics.lineAPIDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+
+	if endpoint == "reply" {
+		result := "sent"
+		if err != nil || res.StatusCode != http.StatusOK {
+			result = "failed"
+		}
+		t.metrics.replies.WithLabelValues(result).Inc()
+	}
+
+	return res, err
+}
+
+// lineEndpoint names the LINE API endpoint of the path. The paths with IDs are grouped as
+// "other" to keep the labels bounded.
+func lineEndpoint(path string) string {
+	switch path {
+	case linebot.APIEndpointReplyMessage:
+		return "reply"
+	case linebot.APIEndpointPushMessage:
+		return "push"
+	default:
+		return "other"
+	}
+}
+
+// lineHTTPClient returns the HTTP client of the LINE client, instrumented with the metrics.
+func (m *metrics) lineHTTPClient() *http.Client {
+	return &http.Client{Transport: &lineTransport{base: http.DefaultTransport, metrics: m}}
+}
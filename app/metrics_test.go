@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// scrape returns the metrics in the Prometheus text format.
+func scrape(t *testing.T, handler http.Handler) string {
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want %v; got %v", http.StatusOK, rr.Code)
+	}
+
+	return rr.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+	a.memeModel = newTimedStore(a.memeModel.(store), a.metrics)
+	a.workers = newEventPool(1, 10, dropPolicy, a.metrics.countEvents(func(job webhookJob) {}), a.logger)
+	a.metrics.registerEventPool(a.workers)
+	handler := a.routes()
+
+	// When.
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+	a.findMemes(context.Background(), "我就爛.jpg 沒有這張圖.jpg")
+	a.workers.submit(context.Background(), webhookJob{event: &linebot.Event{Type: linebot.EventTypeFollow}})
+	a.workers.close()
+
+	// Want.
+	got := scrape(t, handler)
+	for _, want := range []string{
+		`memebot_http_requests_total{route="/",status="200"} 1`,
+		`memebot_http_requests_total{route="/healthz",status="200"} 1`,
+		`memebot_http_request_duration_seconds_count{route="/"} 1`,
+		`memebot_meme_matches_total{kind="exact"} 1`,
+		`memebot_meme_matches_total{kind="none"} 1`,
+		`memebot_db_query_duration_seconds_count{method="GetAll"} 1`,
+		`memebot_db_query_duration_seconds_count{method="GetMany"} 2`,
+		`memebot_webhook_events_total{type="follow"} 1`,
+		`memebot_event_queue_processed_total 1`,
+		`memebot_event_queue_length 0`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want metrics to contain %q", want)
+		}
+	}
+}
+
+func TestLineTransport(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName   string
+		status     int
+		wantResult string
+	}{
+		{"Sent", http.StatusOK, "sent"},
+		{"Failed", http.StatusBadRequest, "failed"},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub. A fake LINE API.
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				w.Write([]byte("{}"))
+			}))
+			defer server.Close()
+
+			m := newMetrics()
+			bot, err := linebot.New("secret", "token",
+				linebot.WithEndpointBase(server.URL), linebot.WithHTTPClient(m.lineHTTPClient()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// When.
+			bot.ReplyMessage("token", linebot.NewTextMessage("hi")).Do()
+
+			// Want.
+			got := scrape(t, m.handler())
+			for _, want := range []string{
+				`memebot_replies_total{result="` + tc.wantResult + `"} 1`,
+				`memebot_line_api_duration_seconds_count{endpoint="reply"} 1`,
+			} {
+				if !strings.Contains(got, want) {
+					t.Errorf("want metrics to contain %q", want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/YuChaoGithub/meme-linebot/config"
+	"github.com/YuChaoGithub/meme-linebot/database"
+)
+
+const migrateUsage = "Usage: meme-linebot migrate [status | up | down [steps]]"
+
+// Migrate runs the migrate subcommand against the configured database and writes the
+// result to out. The args are "status", "up" or "down [steps]" (one step by default).
+func Migrate(config *config.Config, args []string, out io.Writer) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := connectDB(config.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db, config.DB.Dialect)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			if s.Applied {
+				fmt.Fprintf(out, "%04d %-32s applied at %v\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Fprintf(out, "%04d %-32s pending\n", s.Version, s.Name)
+			}
+		}
+	case "up":
+		count, err := migrator.Up()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "Applied %d migrations.\n", count)
+	case "down":
+		steps := 1
+		if len(args) == 2 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+
+		count, err := migrator.Down(steps)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "Rolled back %d migrations.\n", count)
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
+
+// connectDB opens the database for a subcommand, failing at once if it is unreachable.
+func connectDB(c config.DBConfig) (*sql.DB, error) {
+	db, err := sql.Open(c.Dialect, c.ConnectionURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// APIKey is a named key of the admin APIs, allowed to use the APIs of its scopes. Only the
+// hash of the key is stored.
+type APIKey struct {
+	Name      string
+	Hash      string // Hex-encoded SHA-256 of the key.
+	Scopes    []string
+	CreatedAt time.Time
+	RevokedAt time.Time // Zero if the key is active.
+}
+
+// Revoked tells whether the key is revoked.
+func (k APIKey) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// HasScope tells whether the key is allowed to use the APIs of the scope.
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CreateAPIKey saves a new key. It returns ErrDuplicateKey if the name already exists.
+func (m *MemeModel) CreateAPIKey(key APIKey) error {
+	stmt := `INSERT INTO api_keys (name, key_hash, scopes, created_at) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (name) DO NOTHING`
+	res, err := m.DB.Exec(stmt, key.Name, key.Hash, strings.Join(key.Scopes, " "), key.CreatedAt.UTC())
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDuplicateKey
+	}
+
+	return nil
+}
+
+// GetAPIKey returns the key of the name.
+func (m *MemeModel) GetAPIKey(name string) (APIKey, error) {
+	stmt := `SELECT name, key_hash, scopes, created_at, revoked_at FROM api_keys WHERE name = $1`
+	return scanAPIKey(m.DB.QueryRow(stmt, name))
+}
+
+// GetAPIKeyByHash returns the key with the hash.
+func (m *MemeModel) GetAPIKeyByHash(hash string) (APIKey, error) {
+	stmt := `SELECT name, key_hash, scopes, created_at, revoked_at FROM api_keys WHERE key_hash = $1`
+	return scanAPIKey(m.DB.QueryRow(stmt, hash))
+}
+
+// ListAPIKeys returns all keys, including the revoked ones, ordered by name.
+func (m *MemeModel) ListAPIKeys() ([]APIKey, error) {
+	stmt := `SELECT name, key_hash, scopes, created_at, revoked_at FROM api_keys ORDER BY name`
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, key)
+	}
+
+	return res, rows.Err()
+}
+
+// RevokeAPIKey revokes the key of the name at the time. Revoking a revoked key keeps its
+// original revocation time.
+func (m *MemeModel) RevokeAPIKey(name string, at time.Time) error {
+	key, err := m.GetAPIKey(name)
+	if err != nil {
+		return err
+	}
+	if key.Revoked() {
+		return nil
+	}
+
+	stmt := `UPDATE api_keys SET revoked_at = $1 WHERE name = $2`
+	_, err = m.DB.Exec(stmt, at.UTC(), name)
+	return err
+}
+
+// scanAPIKey scans a row of the api_keys table.
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (APIKey, error) {
+	key := APIKey{}
+	var scopes string
+	var revokedAt sql.NullTime
+
+	err := row.Scan(&key.Name, &key.Hash, &scopes, &key.CreatedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return key, ErrNoRecord
+	} else if err != nil {
+		return key, err
+	}
+
+	key.Scopes = strings.Fields(scopes)
+	if revokedAt.Valid {
+		key.RevokedAt = revokedAt.Time
+	}
+
+	return key, nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditEntry is a change made through the admin APIs.
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	Actor     string    `json:"actor"`  // The name of the API key.
+	Action    string    `json:"action"` // e.g. "meme.delete".
+	MemeName  string    `json:"name"`
+	OldValue  string    `json:"old_value"` // Empty if there was none, e.g. for a new meme.
+	NewValue  string    `json:"new_value"` // Empty if there is none, e.g. for a deleted meme.
+	ClientIP  string    `json:"client_ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter selects the audit entries. The zero value of a field matches every entry.
+type AuditFilter struct {
+	Actor    string
+	Action   string
+	MemeName string
+	Since    time.Time // Inclusive.
+	Until    time.Time // Exclusive.
+	Before   int       // Only the entries whose IDs are less than it, to get the next page.
+	Limit    int
+}
+
+// match tells whether the entry is selected by the filter, ignoring the limit.
+func (f AuditFilter) match(e AuditEntry) bool {
+	return (f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.MemeName == "" || e.MemeName == f.MemeName) &&
+		(f.Since.IsZero() || !e.CreatedAt.Before(f.Since)) &&
+		(f.Until.IsZero() || e.CreatedAt.Before(f.Until)) &&
+		(f.Before == 0 || e.ID < f.Before)
+}
+
+// RecordAudit appends an entry to the audit log. The ID of the entry is ignored.
+func (m *MemeModel) RecordAudit(e AuditEntry) error {
+	stmt := `INSERT INTO audit_log (actor, action, meme_name, old_value, new_value, client_ip, created_at)
+	 VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := m.DB.Exec(stmt, e.Actor, e.Action, e.MemeName, e.OldValue, e.NewValue, e.ClientIP, e.CreatedAt.UTC())
+	return err
+}
+
+// AuditLog returns at most limit entries selected by the filter, newest first.
+func (m *MemeModel) AuditLog(f AuditFilter) ([]AuditEntry, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, condition+" $"+strconv.Itoa(len(args)))
+	}
+
+	if f.Actor != "" {
+		where("actor =", f.Actor)
+	}
+	if f.Action != "" {
+		where("action =", f.Action)
+	}
+	if f.MemeName != "" {
+		where("meme_name =", f.MemeName)
+	}
+	if !f.Since.IsZero() {
+		where("created_at >=", f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		where("created_at <", f.Until.UTC())
+	}
+	if f.Before != 0 {
+		where("id <", f.Before)
+	}
+
+	stmt := `SELECT id, actor, action, meme_name, old_value, new_value, client_ip, created_at FROM audit_log`
+	if len(conditions) > 0 {
+		stmt += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, f.Limit)
+	stmt += " ORDER BY id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []AuditEntry{}
+	for rows.Next() {
+		e := AuditEntry{}
+		err = rows.Scan(&e.ID, &e.Actor, &e.Action, &e.MemeName, &e.OldValue, &e.NewValue, &e.ClientIP, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// MarkEvent records the webhook event as processed at the time. It returns false if the event
+// is already recorded, i.e. it is a redelivery.
+func (m *MemeModel) MarkEvent(eventID string, at time.Time) (bool, error) {
+	stmt := `INSERT INTO webhook_events (event_id, processed_at) VALUES ($1, $2) ON CONFLICT (event_id) DO NOTHING`
+	res, err := m.DB.Exec(stmt, eventID, at.UTC())
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// PurgeEvents removes the webhook events processed before the time.
+func (m *MemeModel) PurgeEvents(before time.Time) error {
+	stmt := `DELETE FROM webhook_events WHERE processed_at < $1`
+	_, err := m.DB.Exec(stmt, before.UTC())
+	return err
+}
@@ -2,6 +2,11 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -10,89 +15,318 @@ const (
 	similarityThreshold = 0.15
 )
 
-// MemeModel defines the database which the functions operate on.
+// MemeModel is the SQL implementation of MemeStore. The statements are shared by the
+// PostgreSQL and SQLite dialects.
 type MemeModel struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Logger *slog.Logger // Optional. The default logger is used if nil.
 }
 
-// MemeEntry represents an entry of a meme in the database.
+// MemeEntry represents a meme image in the database along with all of its alias names.
 type MemeEntry struct {
-	Name string
-	Link string
+	ID      int
+	Link    string
+	Aliases []string
 }
 
-// GetAll returns a list of all memes.
+// GetAll returns a list of all memes, ordered by their first alias. The aliases of each meme
+// are sorted and suffixed with nameSuffix, ready to be sent as keywords.
 func (m *MemeModel) GetAll() ([]MemeEntry, error) {
+	stmt := `SELECT m.id, m.url, a.name FROM memes m JOIN aliases a ON a.meme_id = m.id
+	 WHERE m.deleted_at IS NULL ORDER BY a.name ASC`
+	return m.queryEntries(stmt)
+}
+
+// Random returns a random meme. The aliases are the same as GetAll.
+func (m *MemeModel) Random() (MemeEntry, error) {
+	stmt := `SELECT m.id, m.url, a.name FROM memes m JOIN aliases a ON a.meme_id = m.id
+	 WHERE m.id = (SELECT id FROM memes WHERE deleted_at IS NULL ORDER BY RANDOM() LIMIT 1) ORDER BY a.name ASC`
+	entries, err := m.queryEntries(stmt)
+	if err != nil {
+		return MemeEntry{}, err
+	} else if len(entries) == 0 {
+		return MemeEntry{}, ErrNoRecord
+	}
+
+	return entries[0], nil
+}
+
+// Recent returns the limit most recently added memes, newest first. The aliases are the same
+// as GetAll.
+func (m *MemeModel) Recent(limit int) ([]MemeEntry, error) {
+	stmt := `SELECT m.id, m.url, a.name FROM memes m JOIN aliases a ON a.meme_id = m.id
+	 WHERE m.id IN (SELECT id FROM memes WHERE deleted_at IS NULL ORDER BY id DESC LIMIT $1) ORDER BY m.id DESC, a.name ASC`
+	return m.queryEntries(stmt, limit)
+}
+
+// Page returns at most limit memes whose IDs are greater than the cursor, in ID order. Pass
+// the ID of the last meme of a page as the cursor to get the next page, starting from 0.
+// The aliases are the same as GetAll.
+func (m *MemeModel) Page(cursor int, limit int) ([]MemeEntry, error) {
+	stmt := `SELECT m.id, m.url, a.name FROM memes m JOIN aliases a ON a.meme_id = m.id
+	 WHERE m.id IN (SELECT id FROM memes WHERE deleted_at IS NULL AND id > $1 ORDER BY id ASC LIMIT $2) ORDER BY m.id ASC, a.name ASC`
+	return m.queryEntries(stmt, cursor, limit)
+}
+
+// queryEntries runs a statement which selects the meme ID, URL and alias name, and groups the
+// aliases by meme. A meme appears at the position of its first row.
+func (m *MemeModel) queryEntries(stmt string, args ...interface{}) ([]MemeEntry, error) {
 	res := []MemeEntry{}
 
-	stmt := `SELECT name, url FROM memes ORDER BY name ASC`
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
 		return res, err
 	}
+	defer rows.Close()
 
+	indices := map[int]int{}
 	for rows.Next() {
-		entry := MemeEntry{}
-		err = rows.Scan(&entry.Name, &entry.Link)
+		var id int
+		var url, alias string
+		err = rows.Scan(&id, &url, &alias)
 		if err != nil {
 			return res, err
 		}
 
-		entry.Name += nameSuffix
-		entry.Link = imgurBaseLink + entry.Link
+		i, ok := indices[id]
+		if !ok {
+			i = len(res)
+			indices[id] = i
+			res = append(res, MemeEntry{ID: id, Link: imgurBaseLink + url})
+		}
 
-		res = append(res, entry)
+		res[i].Aliases = append(res[i].Aliases, alias+nameSuffix)
 	}
 
-	return res, nil
+	return res, rows.Err()
 }
 
 // Get returns the image URL of the meme if it exists.
 func (m *MemeModel) Get(name string) (string, error) {
 	var res string
-	stmt := `SELECT url FROM memes WHERE name = $1`
+	stmt := `SELECT m.url FROM memes m JOIN aliases a ON a.meme_id = m.id WHERE a.name = $1 AND m.deleted_at IS NULL`
 	row := m.DB.QueryRow(stmt, name)
 	err := row.Scan(&res)
-	if err != nil {
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNoRecord
+	} else if err != nil {
 		return "", err
 	}
 
 	return imgurBaseLink + res, nil
 }
 
-// GetFuzzy returns the image URL of the meme with the closest matching name.
-func (m *MemeModel) GetFuzzy(name string) (string, error) {
-	var res string
+// GetMany returns the image URLs of the existing memes among the names, keyed by name.
+func (m *MemeModel) GetMany(names []string) (map[string]string, error) {
+	res := map[string]string{}
+	if len(names) == 0 {
+		return res, nil
+	}
+
+	placeholders := make([]string, len(names))
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = name
+	}
+
+	stmt := `SELECT a.name, m.url FROM memes m JOIN aliases a ON a.meme_id = m.id
+	 WHERE m.deleted_at IS NULL AND a.name IN (` + strings.Join(placeholders, ", ") + `)`
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
 
-	stmt := `WITH temp AS (SELECT url, SIMILARITY(name, $1) AS sim FROM memes)
-	 SELECT url FROM temp WHERE sim > $2 ORDER BY sim DESC LIMIT 1`
-	row := m.DB.QueryRow(stmt, name, similarityThreshold)
+	for rows.Next() {
+		var name, url string
+		if err = rows.Scan(&name, &url); err != nil {
+			return res, err
+		}
+		res[name] = imgurBaseLink + url
+	}
+
+	return res, rows.Err()
+}
+
+// GetID returns the ID of the meme which the name refers to.
+func (m *MemeModel) GetID(name string) (int, error) {
+	var res int
+	stmt := `SELECT a.meme_id FROM aliases a JOIN memes m ON m.id = a.meme_id WHERE a.name = $1 AND m.deleted_at IS NULL`
+	row := m.DB.QueryRow(stmt, name)
 	err := row.Scan(&res)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNoRecord
+	} else if err != nil {
+		return 0, err
+	}
+
+	return res, nil
+}
+
+// ScoredMeme is a meme alias matching a search query, with its similarity to the query.
+type ScoredMeme struct {
+	Name       string  `json:"name"`
+	Link       string  `json:"link"`
+	Similarity float64 `json:"similarity"`
+}
+
+// Search returns at most limit aliases whose similarity to the query is above
+// similarityThreshold, most similar first.
+func (m *MemeModel) Search(query string, limit int) ([]ScoredMeme, error) {
+	res := []ScoredMeme{}
+
+	stmt := `SELECT a.name, m.url, SIMILARITY(a.name, $1) AS sim FROM aliases a JOIN memes m ON m.id = a.meme_id
+	 WHERE m.deleted_at IS NULL AND SIMILARITY(a.name, $1) > $2 ORDER BY sim DESC, a.name ASC LIMIT $3`
+	rows, err := m.DB.Query(stmt, query, similarityThreshold, limit)
 	if err != nil {
-		return "", err
+		return res, err
 	}
+	defer rows.Close()
 
-	return imgurBaseLink + res, nil
+	for rows.Next() {
+		scored := ScoredMeme{}
+		if err = rows.Scan(&scored.Name, &scored.Link, &scored.Similarity); err != nil {
+			return res, err
+		}
+
+		scored.Link = imgurBaseLink + scored.Link
+
+		res = append(res, scored)
+	}
+
+	return res, rows.Err()
 }
 
-// Insert inserts a meme entry to the database.
+// Insert inserts a meme entry to the database. If a meme with the same URL exists, the name
+// becomes its new alias; otherwise a new meme is created. It returns ErrTrashed if the name or
+// URL belongs to a meme in the trash.
 func (m *MemeModel) Insert(name string, url string) error {
-	stmt := `INSERT INTO memes (name, url) VALUES ($1, $2)`
-	_, err := m.DB.Exec(stmt, name, url)
+	return m.inTx(func(tx *sql.Tx) error {
+		var trashed int
+		stmt := `SELECT COUNT(*) FROM memes WHERE deleted_at IS NOT NULL
+		 AND (url = $1 OR id IN (SELECT meme_id FROM aliases WHERE name = $2))`
+		if err := tx.QueryRow(stmt, url, name).Scan(&trashed); err != nil {
+			return err
+		} else if trashed > 0 {
+			return ErrTrashed
+		}
+
+		stmt = `INSERT INTO memes (url) VALUES ($1) ON CONFLICT (url) DO NOTHING`
+		_, err := tx.Exec(stmt, url)
+		if err != nil {
+			return err
+		}
+
+		stmt = `INSERT INTO aliases (meme_id, name) SELECT id, $2 FROM memes WHERE url = $1`
+		_, err = tx.Exec(stmt, url, name)
+		return err
+	})
+}
+
+// Delete moves the meme which the name refers to, along with all of its aliases, to the trash.
+// Deleting a nonexistent name is not an error.
+func (m *MemeModel) Delete(name string) error {
+	stmt := `UPDATE memes SET deleted_at = $2
+	 WHERE deleted_at IS NULL AND id = (SELECT meme_id FROM aliases WHERE name = $1)`
+	_, err := m.DB.Exec(stmt, name, time.Now().UTC())
+	return err
+}
+
+// AddAlias adds a new alias name to the meme.
+func (m *MemeModel) AddAlias(memeID int, name string) error {
+	stmt := `INSERT INTO aliases (meme_id, name) SELECT id, $2 FROM memes WHERE id = $1 AND deleted_at IS NULL`
+	res, err := m.DB.Exec(stmt, memeID, name)
 	if err != nil {
 		return err
 	}
 
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNoRecord
+	}
+
 	return nil
 }
 
-// Delete deletes a meme entry from the database.
-func (m *MemeModel) Delete(name string) error {
-	stmt := `DELETE FROM memes WHERE name = $1`
-	_, err := m.DB.Exec(stmt, name)
+// RemoveAlias removes an alias name from its meme. The last alias of a meme cannot be
+// removed; use Delete to delete the meme instead.
+func (m *MemeModel) RemoveAlias(name string) error {
+	return m.inTx(func(tx *sql.Tx) error {
+		var count int
+		stmt := `SELECT COUNT(*) FROM aliases WHERE meme_id = (SELECT a.meme_id FROM aliases a
+		 JOIN memes m ON m.id = a.meme_id WHERE a.name = $1 AND m.deleted_at IS NULL)`
+		if err := tx.QueryRow(stmt, name).Scan(&count); err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return ErrNoRecord
+		} else if count == 1 {
+			return ErrLastAlias
+		}
+
+		stmt = `DELETE FROM aliases WHERE name = $1`
+		_, err := tx.Exec(stmt, name)
+		return err
+	})
+}
+
+// ListAliases returns the sorted alias names of the meme.
+func (m *MemeModel) ListAliases(memeID int) ([]string, error) {
+	res := []string{}
+
+	stmt := `SELECT a.name FROM aliases a JOIN memes m ON m.id = a.meme_id
+	 WHERE a.meme_id = $1 AND m.deleted_at IS NULL ORDER BY a.name ASC`
+	rows, err := m.DB.Query(stmt, memeID)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return res, err
+		}
+		res = append(res, name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return res, err
+	}
+
+	// Every meme has at least one alias.
+	if len(res) == 0 {
+		return res, ErrNoRecord
+	}
+
+	return res, nil
+}
+
+// inTx runs fn in a transaction, which is committed if fn succeeds and rolled back otherwise.
+func (m *MemeModel) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := m.DB.Begin()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			m.logger().Error("rolling back the transaction", "error", rbErr, "cause", err)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// logger returns the logger of the model.
+func (m *MemeModel) logger() *slog.Logger {
+	if m.Logger == nil {
+		return slog.Default()
+	}
+
+	return m.Logger
 }
@@ -10,7 +10,7 @@ func TestGetAll(t *testing.T) {
 	db, teardown := newTestDB(t)
 	defer teardown()
 
-	m := MemeModel{db}
+	m := MemeModel{DB: db}
 
 	// When.
 	entries, err := m.GetAll()
@@ -20,14 +20,15 @@ func TestGetAll(t *testing.T) {
 
 	// Want.
 	wantMemes := []MemeEntry{
-		{Name: "adios", Link: "6UegMI2.png"},
-		{Name: "bonjour", Link: "qg8sB6f.png"},
-		{Name: "honest work", Link: "BPCZHUi.png"},
-		{Name: "it ain't much, but it's honest work", Link: "BPCZHUi.png"},
-		{Name: "我就爛", Link: "t9WaxTw.png"},
+		{ID: 2, Link: "6UegMI2.png", Aliases: []string{"adios"}},
+		{ID: 3, Link: "qg8sB6f.png", Aliases: []string{"bonjour"}},
+		{ID: 4, Link: "BPCZHUi.png", Aliases: []string{"honest work", "it ain't much, but it's honest work"}},
+		{ID: 1, Link: "t9WaxTw.png", Aliases: []string{"我就爛"}},
 	}
 	for i := range wantMemes {
-		wantMemes[i].Name += nameSuffix
+		for j := range wantMemes[i].Aliases {
+			wantMemes[i].Aliases[j] += nameSuffix
+		}
 		wantMemes[i].Link = imgurBaseLink + wantMemes[i].Link
 	}
 
@@ -55,7 +56,7 @@ func TestGet(t *testing.T) {
 			db, teardown := newTestDB(t)
 			defer teardown()
 
-			m := MemeModel{db}
+			m := MemeModel{DB: db}
 
 			// When.
 			url, err := m.Get(tc.memeName)
@@ -73,7 +74,7 @@ func TestGet(t *testing.T) {
 	}
 }
 
-func TestGetFuzzy(t *testing.T) {
+func TestSearchClosest(t *testing.T) {
 	// Testcases.
 	tests := []struct {
 		testName string
@@ -92,15 +93,21 @@ func TestGetFuzzy(t *testing.T) {
 			db, teardown := newTestDB(t)
 			defer teardown()
 
-			m := MemeModel{db}
+			m := MemeModel{DB: db}
 
 			// When.
-			url, err := m.GetFuzzy(tc.memeName)
+			results, err := m.Search(tc.memeName, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
 
-			// Want.
-			wantURL := tc.wantURL
-			if err == nil {
-				wantURL = imgurBaseLink + wantURL
+			// Want. The closest meme comes first.
+			url, wantURL := "", ""
+			if len(results) > 0 {
+				url = results[0].Link
+			}
+			if tc.wantURL != "" {
+				wantURL = imgurBaseLink + tc.wantURL
 			}
 
 			if url != wantURL {
@@ -130,7 +137,7 @@ func TestInsert(t *testing.T) {
 			db, teardown := newTestDB(t)
 			defer teardown()
 
-			m := MemeModel{db}
+			m := MemeModel{DB: db}
 
 			// When.
 			err := m.Insert(tc.memeName, tc.memeURL)
@@ -162,7 +169,7 @@ func TestDelete(t *testing.T) {
 			db, teardown := newTestDB(t)
 			defer teardown()
 
-			m := MemeModel{db}
+			m := MemeModel{DB: db}
 
 			// When.
 			err := m.Delete(tc.memeName)
@@ -175,3 +182,75 @@ func TestDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestAliases(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	testAliases(t, &MemeModel{DB: db})
+}
+
+func TestUsages(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	testUsages(t, &MemeModel{DB: db})
+}
+
+func TestGetMany(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	testGetMany(t, &MemeModel{DB: db})
+}
+
+func TestChatSettings(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	testChatSettings(t, &MemeModel{DB: db})
+}
+
+func TestEvents(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	testEvents(t, &MemeModel{DB: db})
+}
+
+func TestDiscovery(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	testDiscovery(t, &MemeModel{DB: db})
+}
+
+func TestAPIKeys(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	testAPIKeys(t, &MemeModel{DB: db})
+}
+
+func TestAuditLog(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	testAuditLog(t, &MemeModel{DB: db})
+}
+
+func TestTrash(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	testTrash(t, &MemeModel{DB: db})
+}
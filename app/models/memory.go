@@ -0,0 +1,682 @@
+package models
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryMemeModel is an in-memory implementation of MemeStore.
+// It is meant for local development and tests where PostgreSQL is not available.
+type MemoryMemeModel struct {
+	mu      sync.RWMutex
+	nextID  int
+	memes   map[int]string // Meme ID to imgur ID.
+	aliases map[string]int // Alias name to meme ID.
+	usages  []memoryUsage
+	trash   map[int]TrashedMeme // Meme ID to the deleted meme, whose aliases stay reserved.
+
+	settings map[string]ChatSettings // Chat ID to the chat settings.
+	events   map[string]time.Time    // Webhook event ID to the processed time.
+	apiKeys  map[string]APIKey       // Name to the API key.
+	audits   []AuditEntry            // In the recorded order.
+}
+
+// memoryUsage is a usage along with the ID of the sent meme.
+type memoryUsage struct {
+	Usage
+	memeID int
+}
+
+// NewMemoryMemeModel returns an empty in-memory meme storage.
+func NewMemoryMemeModel() *MemoryMemeModel {
+	return &MemoryMemeModel{
+		nextID:  1,
+		memes:   map[int]string{},
+		aliases: map[string]int{},
+		trash:   map[int]TrashedMeme{},
+
+		settings: map[string]ChatSettings{},
+		events:   map[string]time.Time{},
+		apiKeys:  map[string]APIKey{},
+	}
+}
+
+// GetAll returns a list of all memes, ordered by their first alias. The aliases of each meme
+// are sorted and suffixed with nameSuffix, ready to be sent as keywords.
+func (m *MemoryMemeModel) GetAll() ([]MemeEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.aliases))
+	for name := range m.aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := []MemeEntry{}
+	indices := map[int]int{}
+	for _, name := range names {
+		id := m.aliases[name]
+
+		i, ok := indices[id]
+		if !ok {
+			i = len(res)
+			indices[id] = i
+			res = append(res, MemeEntry{ID: id, Link: imgurBaseLink + m.memes[id]})
+		}
+
+		res[i].Aliases = append(res[i].Aliases, name+nameSuffix)
+	}
+
+	return res, nil
+}
+
+// Random returns a random meme. The aliases are the same as GetAll.
+func (m *MemoryMemeModel) Random() (MemeEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.memes) == 0 {
+		return MemeEntry{}, ErrNoRecord
+	}
+
+	ids := m.sortedIDs()
+	return m.entry(ids[rand.Intn(len(ids))]), nil
+}
+
+// Recent returns the limit most recently added memes, newest first. The aliases are the same
+// as GetAll.
+func (m *MemoryMemeModel) Recent(limit int) ([]MemeEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := []MemeEntry{}
+	ids := m.sortedIDs()
+	for i := len(ids) - 1; i >= 0 && len(res) < limit; i-- {
+		res = append(res, m.entry(ids[i]))
+	}
+
+	return res, nil
+}
+
+// Page returns at most limit memes whose IDs are greater than the cursor, in ID order.
+// The aliases are the same as GetAll.
+func (m *MemoryMemeModel) Page(cursor int, limit int) ([]MemeEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := []MemeEntry{}
+	for _, id := range m.sortedIDs() {
+		if len(res) == limit {
+			break
+		}
+		if id > cursor {
+			res = append(res, m.entry(id))
+		}
+	}
+
+	return res, nil
+}
+
+// sortedIDs returns the IDs of all memes in ascending order. The caller must hold the lock.
+func (m *MemoryMemeModel) sortedIDs() []int {
+	ids := make([]int, 0, len(m.memes))
+	for id := range m.memes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	return ids
+}
+
+// entry returns the meme with its sorted and suffixed aliases. The caller must hold the lock.
+func (m *MemoryMemeModel) entry(id int) MemeEntry {
+	res := MemeEntry{ID: id, Link: imgurBaseLink + m.memes[id]}
+	for alias, memeID := range m.aliases {
+		if memeID == id {
+			res.Aliases = append(res.Aliases, alias)
+		}
+	}
+
+	sort.Strings(res.Aliases)
+	for i := range res.Aliases {
+		res.Aliases[i] += nameSuffix
+	}
+
+	return res
+}
+
+// Get returns the image URL of the meme if it exists.
+func (m *MemoryMemeModel) Get(name string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.aliases[name]
+	if !ok {
+		return "", ErrNoRecord
+	}
+
+	return imgurBaseLink + m.memes[id], nil
+}
+
+// GetMany returns the image URLs of the existing memes among the names, keyed by name.
+func (m *MemoryMemeModel) GetMany(names []string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := map[string]string{}
+	for _, name := range names {
+		if id, ok := m.aliases[name]; ok {
+			res[name] = imgurBaseLink + m.memes[id]
+		}
+	}
+
+	return res, nil
+}
+
+// GetID returns the ID of the meme which the name refers to.
+func (m *MemoryMemeModel) GetID(name string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.aliases[name]
+	if !ok {
+		return 0, ErrNoRecord
+	}
+
+	return id, nil
+}
+
+// Search returns at most limit aliases whose similarity to the query is above
+// similarityThreshold, most similar first.
+func (m *MemoryMemeModel) Search(query string, limit int) ([]ScoredMeme, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := []ScoredMeme{}
+	for alias, id := range m.aliases {
+		if sim := similarity(alias, query); sim > similarityThreshold {
+			res = append(res, ScoredMeme{Name: alias, Link: imgurBaseLink + m.memes[id], Similarity: sim})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Similarity != res[j].Similarity {
+			return res[i].Similarity > res[j].Similarity
+		}
+		return res[i].Name < res[j].Name
+	})
+
+	if len(res) > limit {
+		res = res[:limit]
+	}
+
+	return res, nil
+}
+
+// Insert inserts a meme entry. If a meme with the same URL exists, the name becomes its new
+// alias; otherwise a new meme is created. It fails if the name already exists, and returns
+// ErrTrashed if the name or URL belongs to a meme in the trash.
+func (m *MemoryMemeModel) Insert(name string, url string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.aliases[name]; ok {
+		return ErrDuplicateName
+	}
+
+	if _, ok := m.findTrashed(name); ok {
+		return ErrTrashed
+	}
+	for _, meme := range m.trash {
+		if meme.Link == imgurBaseLink+url {
+			return ErrTrashed
+		}
+	}
+
+	id, ok := m.findURL(url)
+	if !ok {
+		id = m.nextID
+		m.nextID++
+		m.memes[id] = url
+	}
+
+	m.aliases[name] = id
+
+	return nil
+}
+
+// findURL returns the ID of the meme with the URL. The caller must hold the lock.
+func (m *MemoryMemeModel) findURL(url string) (int, bool) {
+	for id, memeURL := range m.memes {
+		if memeURL == url {
+			return id, true
+		}
+	}
+
+	return 0, false
+}
+
+// Delete moves the meme which the name refers to, along with all of its aliases, to the trash.
+// Deleting a nonexistent name is not an error.
+func (m *MemoryMemeModel) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.aliases[name]
+	if !ok {
+		return nil
+	}
+
+	meme := TrashedMeme{ID: id, Link: imgurBaseLink + m.memes[id], Aliases: []string{}, DeletedAt: time.Now()}
+	for alias, memeID := range m.aliases {
+		if memeID == id {
+			meme.Aliases = append(meme.Aliases, alias)
+			delete(m.aliases, alias)
+		}
+	}
+	sort.Strings(meme.Aliases)
+
+	m.trash[id] = meme
+	delete(m.memes, id)
+
+	return nil
+}
+
+// ListTrash returns the memes in the trash, most recently deleted first.
+func (m *MemoryMemeModel) ListTrash() ([]TrashedMeme, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.trashed(func(TrashedMeme) bool { return true }), nil
+}
+
+// Restore moves the meme which the name refers to out of the trash.
+func (m *MemoryMemeModel) Restore(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	meme, ok := m.findTrashed(name)
+	if !ok {
+		return ErrNoRecord
+	}
+
+	m.memes[meme.ID] = strings.TrimPrefix(meme.Link, imgurBaseLink)
+	for _, alias := range meme.Aliases {
+		m.aliases[alias] = meme.ID
+	}
+	delete(m.trash, meme.ID)
+
+	return nil
+}
+
+// PurgeTrash permanently deletes the memes moved to the trash before the time, along with their
+// usages, and returns them.
+func (m *MemoryMemeModel) PurgeTrash(before time.Time) ([]TrashedMeme, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := m.trashed(func(meme TrashedMeme) bool { return meme.DeletedAt.Before(before) })
+	for _, meme := range res {
+		delete(m.trash, meme.ID)
+	}
+
+	usages := m.usages[:0]
+	for _, u := range m.usages {
+		if _, ok := m.memes[u.memeID]; ok {
+			usages = append(usages, u)
+		} else if _, ok := m.trash[u.memeID]; ok {
+			usages = append(usages, u)
+		}
+	}
+	m.usages = usages
+
+	return res, nil
+}
+
+// findTrashed returns the meme in the trash with the alias name. The caller must hold the lock.
+func (m *MemoryMemeModel) findTrashed(name string) (TrashedMeme, bool) {
+	for _, meme := range m.trash {
+		for _, alias := range meme.Aliases {
+			if alias == name {
+				return meme, true
+			}
+		}
+	}
+
+	return TrashedMeme{}, false
+}
+
+// trashed returns the memes in the trash matching the filter, most recently deleted first.
+// The caller must hold the lock.
+func (m *MemoryMemeModel) trashed(filter func(meme TrashedMeme) bool) []TrashedMeme {
+	res := []TrashedMeme{}
+	for _, meme := range m.trash {
+		if filter(meme) {
+			meme.Aliases = append([]string{}, meme.Aliases...)
+			res = append(res, meme)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].DeletedAt.Equal(res[j].DeletedAt) {
+			return res[i].DeletedAt.After(res[j].DeletedAt)
+		}
+		return res[i].ID > res[j].ID
+	})
+
+	return res
+}
+
+// AddAlias adds a new alias name to the meme.
+func (m *MemoryMemeModel) AddAlias(memeID int, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.memes[memeID]; !ok {
+		return ErrNoRecord
+	}
+
+	if _, ok := m.aliases[name]; ok {
+		return ErrDuplicateName
+	}
+	if _, ok := m.findTrashed(name); ok {
+		return ErrDuplicateName
+	}
+
+	m.aliases[name] = memeID
+
+	return nil
+}
+
+// RemoveAlias removes an alias name from its meme. The last alias of a meme cannot be
+// removed; use Delete to delete the meme instead.
+func (m *MemoryMemeModel) RemoveAlias(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.aliases[name]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	count := 0
+	for _, memeID := range m.aliases {
+		if memeID == id {
+			count++
+		}
+	}
+	if count == 1 {
+		return ErrLastAlias
+	}
+
+	delete(m.aliases, name)
+
+	return nil
+}
+
+// ListAliases returns the sorted alias names of the meme.
+func (m *MemoryMemeModel) ListAliases(memeID int) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := []string{}
+	for alias, id := range m.aliases {
+		if id == memeID {
+			res = append(res, alias)
+		}
+	}
+
+	if len(res) == 0 {
+		return res, ErrNoRecord
+	}
+
+	sort.Strings(res)
+
+	return res, nil
+}
+
+// RecordUsage records a meme sent by the bot.
+func (m *MemoryMemeModel) RecordUsage(u Usage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.findURL(strings.TrimPrefix(u.Link, imgurBaseLink))
+	if !ok {
+		return ErrNoRecord
+	}
+
+	m.usages = append(m.usages, memoryUsage{Usage: u, memeID: id})
+
+	return nil
+}
+
+// TopMemes returns the limit most sent memes since the given time.
+func (m *MemoryMemeModel) TopMemes(since time.Time, limit int) ([]MemeStat, error) {
+	return m.topMemes(func(u memoryUsage) bool {
+		return !u.Time.Before(since)
+	}, limit), nil
+}
+
+// TopMemesBySource returns the limit most sent memes in the source (a user, group or room)
+// since the given time. The sourceID is hashed the same way as Usage.SourceID.
+func (m *MemoryMemeModel) TopMemesBySource(sourceID string, since time.Time, limit int) ([]MemeStat, error) {
+	return m.topMemes(func(u memoryUsage) bool {
+		return u.SourceID == sourceID && !u.Time.Before(since)
+	}, limit), nil
+}
+
+// topMemes counts the usages matching the filter and returns the limit most sent memes.
+func (m *MemoryMemeModel) topMemes(filter func(u memoryUsage) bool, limit int) []MemeStat {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := map[int]int{}
+	for _, u := range m.usages {
+		if _, ok := m.memes[u.memeID]; ok && filter(u) {
+			counts[u.memeID]++
+		}
+	}
+
+	res := []MemeStat{}
+	for id, count := range counts {
+		res = append(res, m.memeStat(id, count))
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].MemeID < res[j].MemeID
+	})
+
+	if len(res) > limit {
+		res = res[:limit]
+	}
+
+	return res
+}
+
+// UnusedMemes returns the memes which have never been sent, ordered by ID.
+func (m *MemoryMemeModel) UnusedMemes() ([]MemeStat, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	used := map[int]struct{}{}
+	for _, u := range m.usages {
+		used[u.memeID] = struct{}{}
+	}
+
+	res := []MemeStat{}
+	for id := range m.memes {
+		if _, ok := used[id]; !ok {
+			res = append(res, m.memeStat(id, 0))
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].MemeID < res[j].MemeID
+	})
+
+	return res, nil
+}
+
+// memeStat returns the stat of the meme with its first alias. The caller must hold the lock.
+func (m *MemoryMemeModel) memeStat(id int, count int) MemeStat {
+	name := ""
+	for alias, memeID := range m.aliases {
+		if memeID == id && (name == "" || alias < name) {
+			name = alias
+		}
+	}
+
+	return MemeStat{MemeID: id, Name: name, Link: imgurBaseLink + m.memes[id], Count: count}
+}
+
+// GetChatSettings returns the settings of the chat, or the default settings if there are none.
+func (m *MemoryMemeModel) GetChatSettings(chatID string) (ChatSettings, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.settings[chatID], nil
+}
+
+// SaveChatSettings saves the settings of the chat.
+func (m *MemoryMemeModel) SaveChatSettings(chatID string, s ChatSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.settings[chatID] = s
+
+	return nil
+}
+
+// MarkEvent records the webhook event as processed at the time. It returns false if the event
+// is already recorded.
+func (m *MemoryMemeModel) MarkEvent(eventID string, at time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.events[eventID]; ok {
+		return false, nil
+	}
+
+	m.events[eventID] = at
+	return true, nil
+}
+
+// PurgeEvents removes the webhook events processed before the time.
+func (m *MemoryMemeModel) PurgeEvents(before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, at := range m.events {
+		if at.Before(before) {
+			delete(m.events, id)
+		}
+	}
+
+	return nil
+}
+
+// CreateAPIKey saves a new key. It returns ErrDuplicateKey if the name already exists.
+func (m *MemoryMemeModel) CreateAPIKey(key APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.apiKeys[key.Name]; ok {
+		return ErrDuplicateKey
+	}
+
+	key.Scopes = append([]string{}, key.Scopes...)
+	m.apiKeys[key.Name] = key
+	return nil
+}
+
+// GetAPIKey returns the key of the name.
+func (m *MemoryMemeModel) GetAPIKey(name string) (APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.apiKeys[name]
+	if !ok {
+		return APIKey{}, ErrNoRecord
+	}
+
+	return key, nil
+}
+
+// GetAPIKeyByHash returns the key with the hash.
+func (m *MemoryMemeModel) GetAPIKeyByHash(hash string) (APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.apiKeys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+
+	return APIKey{}, ErrNoRecord
+}
+
+// ListAPIKeys returns all keys, including the revoked ones, ordered by name.
+func (m *MemoryMemeModel) ListAPIKeys() ([]APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := []APIKey{}
+	for _, key := range m.apiKeys {
+		res = append(res, key)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res, nil
+}
+
+// RevokeAPIKey revokes the key of the name at the time. Revoking a revoked key keeps its
+// original revocation time.
+func (m *MemoryMemeModel) RevokeAPIKey(name string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[name]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	if !key.Revoked() {
+		key.RevokedAt = at
+		m.apiKeys[name] = key
+	}
+
+	return nil
+}
+
+// RecordAudit appends an entry to the audit log. The ID of the entry is ignored.
+func (m *MemoryMemeModel) RecordAudit(e AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e.ID = len(m.audits) + 1
+	m.audits = append(m.audits, e)
+	return nil
+}
+
+// AuditLog returns at most limit entries selected by the filter, newest first.
+func (m *MemoryMemeModel) AuditLog(f AuditFilter) ([]AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := []AuditEntry{}
+	for i := len(m.audits) - 1; i >= 0 && len(res) < f.Limit; i-- {
+		if f.match(m.audits[i]) {
+			res = append(res, m.audits[i])
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMemoryGetAll(t *testing.T) {
+	// Stub.
+	m := newTestMemoryModel(t)
+
+	// When.
+	entries, err := m.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Want.
+	wantMemes := []MemeEntry{
+		{ID: 2, Link: "6UegMI2.png", Aliases: []string{"adios"}},
+		{ID: 3, Link: "qg8sB6f.png", Aliases: []string{"bonjour"}},
+		{ID: 4, Link: "BPCZHUi.png", Aliases: []string{"honest work", "it ain't much, but it's honest work"}},
+		{ID: 1, Link: "t9WaxTw.png", Aliases: []string{"我就爛"}},
+	}
+	for i := range wantMemes {
+		for j := range wantMemes[i].Aliases {
+			wantMemes[i].Aliases[j] += nameSuffix
+		}
+		wantMemes[i].Link = imgurBaseLink + wantMemes[i].Link
+	}
+
+	if !reflect.DeepEqual(entries, wantMemes) {
+		t.Errorf("want:\n%v\ngot:\n%v", wantMemes, entries)
+	}
+}
+
+func TestMemoryGet(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName string
+		memeName string
+		wantURL  string
+	}{
+		{"Chinese", "我就爛", "t9WaxTw.png"},
+		{"With single quote", "it ain't much, but it's honest work", "BPCZHUi.png"},
+		{"Doesn't exist", "ahhhhhhh", ""},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			m := newTestMemoryModel(t)
+
+			// When.
+			url, err := m.Get(tc.memeName)
+
+			// Want.
+			wantURL := tc.wantURL
+			if err == nil {
+				wantURL = imgurBaseLink + wantURL
+			}
+
+			if url != wantURL {
+				t.Errorf("want %v; got %v", wantURL, url)
+			}
+		})
+	}
+}
+
+func TestMemorySearchClosest(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName string
+		memeName string
+		wantURL  string
+	}{
+		{"Chinese", "就爛", "t9WaxTw.png"},
+		{"almost match", "bonjer", "qg8sB6f.png"},
+		{"exact match", "adios", "6UegMI2.png"},
+		{"no match", "xyz", ""},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			m := newTestMemoryModel(t)
+
+			// When.
+			results, err := m.Search(tc.memeName, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Want. The closest meme comes first.
+			url, wantURL := "", ""
+			if len(results) > 0 {
+				url = results[0].Link
+			}
+			if tc.wantURL != "" {
+				wantURL = imgurBaseLink + tc.wantURL
+			}
+
+			if url != wantURL {
+				t.Errorf("want %v; got %v", wantURL, url)
+			}
+		})
+	}
+}
+
+func TestMemoryInsert(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName string
+		memeName string
+		memeURL  string
+		hasErr   bool
+	}{
+		{"Existing Entry", "我就爛", "t9WaxTw.png", true},
+		{"Existing URL but different name", "爛", "t9WaxTw.png", false},
+		{"Doesn't exist", "ah", "txt.png", false},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			m := newTestMemoryModel(t)
+
+			// When.
+			err := m.Insert(tc.memeName, tc.memeURL)
+			hasErr := err != nil
+
+			// Want.
+			if tc.hasErr != hasErr {
+				t.Errorf("want %v; got %v", tc.hasErr, hasErr)
+			}
+		})
+	}
+}
+
+func TestMemoryDelete(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName string
+		memeName string
+		hasErr   bool
+	}{
+		{"Existing Entry", "我就爛", false},
+		{"Doesn't exist", "ah", false},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub.
+			m := newTestMemoryModel(t)
+
+			// When.
+			err := m.Delete(tc.memeName)
+			hasErr := err != nil
+
+			// Want.
+			if tc.hasErr != hasErr {
+				t.Errorf("want %v; got %v", tc.hasErr, hasErr)
+			}
+
+			if _, err := m.Get(tc.memeName); err != ErrNoRecord {
+				t.Errorf("want %v; got %v", ErrNoRecord, err)
+			}
+		})
+	}
+}
+
+func TestMemoryAliases(t *testing.T) {
+	testAliases(t, newTestMemoryModel(t))
+}
+
+func TestMemoryUsages(t *testing.T) {
+	testUsages(t, newTestMemoryModel(t))
+}
+
+func TestMemoryGetMany(t *testing.T) {
+	testGetMany(t, newTestMemoryModel(t))
+}
+
+func TestMemoryChatSettings(t *testing.T) {
+	testChatSettings(t, newTestMemoryModel(t))
+}
+
+func TestMemoryEvents(t *testing.T) {
+	testEvents(t, newTestMemoryModel(t))
+}
+
+func TestMemoryDiscovery(t *testing.T) {
+	testDiscovery(t, newTestMemoryModel(t))
+}
+
+func TestMemoryAPIKeys(t *testing.T) {
+	testAPIKeys(t, newTestMemoryModel(t))
+}
+
+func TestMemoryAuditLog(t *testing.T) {
+	testAuditLog(t, newTestMemoryModel(t))
+}
+
+func TestMemoryTrash(t *testing.T) {
+	testTrash(t, newTestMemoryModel(t))
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoRecord is returned when no meme matches the query.
+var ErrNoRecord = errors.New("models: no matching record found")
+
+// ErrDuplicateName is returned when inserting a meme whose name already exists.
+var ErrDuplicateName = errors.New("models: duplicate meme name")
+
+// ErrDuplicateKey is returned when creating an API key whose name already exists.
+var ErrDuplicateKey = errors.New("models: duplicate API key name")
+
+// ErrTrashed is returned when inserting a meme whose name or URL belongs to a meme in the trash.
+var ErrTrashed = errors.New("models: the meme is in the trash")
+
+// ErrLastAlias is returned when removing the only alias of a meme.
+var ErrLastAlias = errors.New("models: cannot remove the last alias of a meme")
+
+// MemeStore defines the operations on the meme storage. The app only depends on this
+// interface, so the storage backend can be swapped (e.g. PostgreSQL or in-memory).
+type MemeStore interface {
+	// GetAll returns a list of all memes grouped with their aliases.
+	GetAll() ([]MemeEntry, error)
+	// Random returns a random meme.
+	Random() (MemeEntry, error)
+	// Recent returns the limit most recently added memes, newest first.
+	Recent(limit int) ([]MemeEntry, error)
+	// Page returns at most limit memes whose IDs are greater than the cursor, in ID order.
+	Page(cursor int, limit int) ([]MemeEntry, error)
+	// Get returns the image URL of the meme if it exists.
+	Get(name string) (string, error)
+	// GetMany returns the image URLs of the existing memes among the names, keyed by name.
+	GetMany(names []string) (map[string]string, error)
+	// GetID returns the ID of the meme which the name refers to.
+	GetID(name string) (int, error)
+	// Search returns at most limit aliases similar to the query, most similar first.
+	Search(query string, limit int) ([]ScoredMeme, error)
+	// Insert inserts a meme entry, or adds the name as an alias if the URL already exists.
+	Insert(name string, url string) error
+	// Delete moves the meme which the name refers to, along with all of its aliases, to the
+	// trash. The memes in the trash are excluded from every other method.
+	Delete(name string) error
+	// ListTrash returns the memes in the trash, most recently deleted first.
+	ListTrash() ([]TrashedMeme, error)
+	// Restore moves the meme which the name refers to out of the trash.
+	Restore(name string) error
+	// PurgeTrash permanently deletes the memes moved to the trash before the time, and returns them.
+	PurgeTrash(before time.Time) ([]TrashedMeme, error)
+	// AddAlias adds a new alias name to the meme.
+	AddAlias(memeID int, name string) error
+	// RemoveAlias removes an alias name from its meme.
+	RemoveAlias(name string) error
+	// ListAliases returns the sorted alias names of the meme.
+	ListAliases(memeID int) ([]string, error)
+
+	// RecordUsage records a meme sent by the bot.
+	RecordUsage(u Usage) error
+	// TopMemes returns the limit most sent memes since the given time.
+	TopMemes(since time.Time, limit int) ([]MemeStat, error)
+	// TopMemesBySource returns the limit most sent memes in the source since the given time.
+	TopMemesBySource(sourceID string, since time.Time, limit int) ([]MemeStat, error)
+	// UnusedMemes returns the memes which have never been sent.
+	UnusedMemes() ([]MemeStat, error)
+}
+
+// SettingsStore defines the operations on the per-chat settings, keyed by the LINE group, room
+// or user ID of the chat.
+type SettingsStore interface {
+	// GetChatSettings returns the settings of the chat, or the default settings if there are none.
+	GetChatSettings(chatID string) (ChatSettings, error)
+	// SaveChatSettings saves the settings of the chat.
+	SaveChatSettings(chatID string, s ChatSettings) error
+}
+
+// EventStore defines the operations on the processed webhook events, so that a redelivered
+// event is processed only once even if another instance of the app received it.
+type EventStore interface {
+	// MarkEvent records the event as processed. It returns false if it is already recorded.
+	MarkEvent(eventID string, at time.Time) (bool, error)
+	// PurgeEvents removes the events processed before the time.
+	PurgeEvents(before time.Time) error
+}
+
+// APIKeyStore defines the operations on the API keys of the admin APIs.
+type APIKeyStore interface {
+	// CreateAPIKey saves a new key. It returns ErrDuplicateKey if the name already exists.
+	CreateAPIKey(key APIKey) error
+	// GetAPIKey returns the key of the name.
+	GetAPIKey(name string) (APIKey, error)
+	// GetAPIKeyByHash returns the key with the hash.
+	GetAPIKeyByHash(hash string) (APIKey, error)
+	// ListAPIKeys returns all keys, including the revoked ones, ordered by name.
+	ListAPIKeys() ([]APIKey, error)
+	// RevokeAPIKey revokes the key of the name at the time.
+	RevokeAPIKey(name string, at time.Time) error
+}
+
+// AuditStore defines the operations on the audit log of the admin APIs.
+type AuditStore interface {
+	// RecordAudit appends an entry to the audit log. The ID of the entry is ignored.
+	RecordAudit(e AuditEntry) error
+	// AuditLog returns at most limit entries selected by the filter, newest first.
+	AuditLog(f AuditFilter) ([]AuditEntry, error)
+}
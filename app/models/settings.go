@@ -0,0 +1,58 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ChatSettings are the settings of a LINE user, group or room.
+// The zero value is the default settings.
+type ChatSettings struct {
+	// ImplicitTrigger makes the bot reply to a message which exactly equals a meme keyword,
+	// even without the ".jpg" suffix.
+	ImplicitTrigger bool
+
+	// NoQuickReplies stops the bot from attaching the nearest keywords as quick reply buttons
+	// to a fuzzy match.
+	NoQuickReplies bool
+
+	// GreetingMeme and FarewellMeme are the names of the memes sent when members join or leave
+	// the chat. An empty name uses the global default.
+	GreetingMeme string
+	FarewellMeme string
+
+	// MemeCooldown is the number of seconds before the same meme can be sent again in the chat.
+	// 0 uses the global default, and a negative value turns the cooldown off.
+	MemeCooldown int
+}
+
+// GetChatSettings returns the settings of the chat, or the default settings if there are none.
+func (m *MemeModel) GetChatSettings(chatID string) (ChatSettings, error) {
+	res := ChatSettings{}
+
+	stmt := `SELECT implicit_trigger, no_quick_replies, greeting_meme, farewell_meme, meme_cooldown
+	 FROM chat_settings WHERE source_id = $1`
+	err := m.DB.QueryRow(stmt, chatID).Scan(&res.ImplicitTrigger, &res.NoQuickReplies, &res.GreetingMeme,
+		&res.FarewellMeme, &res.MemeCooldown)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ChatSettings{}, nil
+	} else if err != nil {
+		return ChatSettings{}, err
+	}
+
+	return res, nil
+}
+
+// SaveChatSettings saves the settings of the chat.
+func (m *MemeModel) SaveChatSettings(chatID string, s ChatSettings) error {
+	stmt := `INSERT INTO chat_settings
+	 (source_id, implicit_trigger, no_quick_replies, greeting_meme, farewell_meme, meme_cooldown)
+	 VALUES ($1, $2, $3, $4, $5, $6)
+	 ON CONFLICT (source_id) DO UPDATE
+	 SET implicit_trigger = excluded.implicit_trigger, no_quick_replies = excluded.no_quick_replies,
+	 greeting_meme = excluded.greeting_meme, farewell_meme = excluded.farewell_meme,
+	 meme_cooldown = excluded.meme_cooldown`
+	_, err := m.DB.Exec(stmt, chatID, s.ImplicitTrigger, s.NoQuickReplies, s.GreetingMeme, s.FarewellMeme,
+		s.MemeCooldown)
+	return err
+}
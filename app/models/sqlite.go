@@ -0,0 +1,26 @@
+package models
+
+import (
+	"database/sql/driver"
+
+	"modernc.org/sqlite" // Pure-Go SQLite driver, registered as "sqlite".
+)
+
+// SQLite has no pg_trgm extension, so MemeModel's SIMILARITY calls are served by the Go
+// implementation of the trigram similarity. This keeps the fuzzy ranking of both dialects
+// identical with the same SQL statements.
+func init() {
+	err := sqlite.RegisterDeterministicScalarFunction("similarity", 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		a, okA := args[0].(string)
+		b, okB := args[1].(string)
+		if !okA || !okB {
+			// Same as PostgreSQL, NULL in NULL out.
+			return nil, nil
+		}
+
+		return similarity(a, b), nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
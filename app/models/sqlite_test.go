@@ -0,0 +1,184 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSQLiteGetAll(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestSQLiteDB(t)
+	defer teardown()
+
+	m := MemeModel{DB: db}
+
+	// When.
+	entries, err := m.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Want.
+	wantMemes := []MemeEntry{
+		{ID: 2, Link: "6UegMI2.png", Aliases: []string{"adios"}},
+		{ID: 3, Link: "qg8sB6f.png", Aliases: []string{"bonjour"}},
+		{ID: 4, Link: "BPCZHUi.png", Aliases: []string{"honest work", "it ain't much, but it's honest work"}},
+		{ID: 1, Link: "t9WaxTw.png", Aliases: []string{"我就爛"}},
+	}
+	for i := range wantMemes {
+		for j := range wantMemes[i].Aliases {
+			wantMemes[i].Aliases[j] += nameSuffix
+		}
+		wantMemes[i].Link = imgurBaseLink + wantMemes[i].Link
+	}
+
+	if !reflect.DeepEqual(entries, wantMemes) {
+		t.Errorf("want:\n%v\ngot:\n%v", wantMemes, entries)
+	}
+}
+
+func TestSQLiteSearchClosest(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName string
+		memeName string
+		wantURL  string
+	}{
+		{"Chinese", "就爛", "t9WaxTw.png"},
+		{"almost match", "bonjer", "qg8sB6f.png"},
+		{"exact match", "adios", "6UegMI2.png"},
+		{"no match", "xyz", ""},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub and driver.
+			db, teardown := newTestSQLiteDB(t)
+			defer teardown()
+
+			m := MemeModel{DB: db}
+
+			// When.
+			results, err := m.Search(tc.memeName, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Want. The closest meme comes first.
+			url, wantURL := "", ""
+			if len(results) > 0 {
+				url = results[0].Link
+			}
+			if tc.wantURL != "" {
+				wantURL = imgurBaseLink + tc.wantURL
+			}
+
+			if url != wantURL {
+				t.Errorf("want %v; got %v", wantURL, url)
+			}
+		})
+	}
+}
+
+func TestSQLiteInsertAndDelete(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestSQLiteDB(t)
+	defer teardown()
+
+	m := MemeModel{DB: db}
+
+	// When.
+	if err := m.Insert("我就爛", "t9WaxTw.png"); err == nil {
+		t.Errorf("want error inserting an existing entry; got nil")
+	}
+
+	if err := m.Insert("ah", "txt.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	if url, err := m.Get("ah"); err != nil || url != imgurBaseLink+"txt.png" {
+		t.Errorf("want %v; got %v (err: %v)", imgurBaseLink+"txt.png", url, err)
+	}
+
+	if err := m.Delete("ah"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Want.
+	if _, err := m.Get("ah"); err != ErrNoRecord {
+		t.Errorf("want %v; got %v", ErrNoRecord, err)
+	}
+}
+
+func TestSQLiteAliases(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestSQLiteDB(t)
+	defer teardown()
+
+	testAliases(t, &MemeModel{DB: db})
+}
+
+func TestSQLiteUsages(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestSQLiteDB(t)
+	defer teardown()
+
+	testUsages(t, &MemeModel{DB: db})
+}
+
+func TestSQLiteGetMany(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestSQLiteDB(t)
+	defer teardown()
+
+	testGetMany(t, &MemeModel{DB: db})
+}
+
+func TestSQLiteChatSettings(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestSQLiteDB(t)
+	defer teardown()
+
+	testChatSettings(t, &MemeModel{DB: db})
+}
+
+func TestSQLiteEvents(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestSQLiteDB(t)
+	defer teardown()
+
+	testEvents(t, &MemeModel{DB: db})
+}
+
+func TestSQLiteDiscovery(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestSQLiteDB(t)
+	defer teardown()
+
+	testDiscovery(t, &MemeModel{DB: db})
+}
+
+func TestSQLiteAPIKeys(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestSQLiteDB(t)
+	defer teardown()
+
+	testAPIKeys(t, &MemeModel{DB: db})
+}
+
+func TestSQLiteAuditLog(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestSQLiteDB(t)
+	defer teardown()
+
+	testAuditLog(t, &MemeModel{DB: db})
+}
+
+func TestSQLiteTrash(t *testing.T) {
+	// Stub and driver.
+	db, teardown := newTestSQLiteDB(t)
+	defer teardown()
+
+	testTrash(t, &MemeModel{DB: db})
+}
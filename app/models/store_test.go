@@ -0,0 +1,557 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testAliases tests the alias operations of a storage filled with the mock data.
+// It is shared by the tests of all storage backends.
+func testAliases(t *testing.T, m MemeStore) {
+	// Stub.
+	id, err := m.GetID("honest work")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Inserting an existing URL adds an alias.
+	if err = m.Insert("honest", "BPCZHUi.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Adding aliases.
+	if err = m.AddAlias(id, "farmer"); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.AddAlias(id, "我就爛"); err == nil {
+		t.Errorf("want error adding an existing alias; got nil")
+	}
+	if err = m.AddAlias(12345, "nobody"); err != ErrNoRecord {
+		t.Errorf("want %v; got %v", ErrNoRecord, err)
+	}
+
+	aliases, err := m.ListAliases(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantAliases := []string{"farmer", "honest", "honest work", "it ain't much, but it's honest work"}
+	if !reflect.DeepEqual(aliases, wantAliases) {
+		t.Errorf("want %v; got %v", wantAliases, aliases)
+	}
+
+	// Removing aliases.
+	if err = m.RemoveAlias("farmer"); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.RemoveAlias("farmer"); err != ErrNoRecord {
+		t.Errorf("want %v; got %v", ErrNoRecord, err)
+	}
+	if err = m.RemoveAlias("我就爛"); err != ErrLastAlias {
+		t.Errorf("want %v; got %v", ErrLastAlias, err)
+	}
+
+	// Deleting a meme deletes all of its aliases.
+	if err = m.Delete("honest"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Get("it ain't much, but it's honest work"); err != ErrNoRecord {
+		t.Errorf("want %v; got %v", ErrNoRecord, err)
+	}
+	if _, err = m.ListAliases(id); err != ErrNoRecord {
+		t.Errorf("want %v; got %v", ErrNoRecord, err)
+	}
+}
+
+// testUsages tests the usage statistics of a storage filled with the mock data.
+// It is shared by the tests of all storage backends.
+func testUsages(t *testing.T, m MemeStore) {
+	// Stub.
+	now := time.Now()
+	usages := []Usage{
+		{Link: imgurBaseLink + "t9WaxTw.png", Keyword: "我就爛", SourceType: SourceTypeGroup, SourceID: "g1", Time: now},
+		{Link: imgurBaseLink + "t9WaxTw.png", Keyword: "就爛", Fuzzy: true, SourceType: SourceTypeGroup, SourceID: "g1", Time: now},
+		{Link: imgurBaseLink + "BPCZHUi.png", Keyword: "honest work", SourceType: SourceTypeUser, SourceID: "u1", Time: now},
+		{Link: imgurBaseLink + "BPCZHUi.png", Keyword: "honest work", SourceType: SourceTypeUser, SourceID: "u1", Time: now},
+		{Link: imgurBaseLink + "BPCZHUi.png", Keyword: "honest work", SourceType: SourceTypeUser, SourceID: "u1", Time: now.AddDate(0, 0, -10)},
+	}
+	for _, u := range usages {
+		if err := m.RecordUsage(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.RecordUsage(Usage{Link: imgurBaseLink + "nope.png", Time: now}); err != ErrNoRecord {
+		t.Errorf("want %v; got %v", ErrNoRecord, err)
+	}
+
+	// Testcases.
+	tests := []struct {
+		testName  string
+		query     func() ([]MemeStat, error)
+		wantNames []string
+		wantCount []int
+	}{
+		{"All time", func() ([]MemeStat, error) { return m.TopMemes(time.Time{}, 10) }, []string{"honest work", "我就爛"}, []int{3, 2}},
+		{"Last week", func() ([]MemeStat, error) { return m.TopMemes(now.AddDate(0, 0, -7), 10) }, []string{"我就爛", "honest work"}, []int{2, 2}},
+		{"Limit", func() ([]MemeStat, error) { return m.TopMemes(time.Time{}, 1) }, []string{"honest work"}, []int{3}},
+		{"By source", func() ([]MemeStat, error) { return m.TopMemesBySource("g1", time.Time{}, 10) }, []string{"我就爛"}, []int{2}},
+		{"Unused", m.UnusedMemes, []string{"adios", "bonjour"}, []int{0, 0}},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			stats, err := tc.query()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Want.
+			names, counts := []string{}, []int{}
+			for _, s := range stats {
+				names = append(names, s.Name)
+				counts = append(counts, s.Count)
+			}
+
+			if !reflect.DeepEqual(names, tc.wantNames) || !reflect.DeepEqual(counts, tc.wantCount) {
+				t.Errorf("want %v %v; got %v %v", tc.wantNames, tc.wantCount, names, counts)
+			}
+		})
+	}
+}
+
+// testGetMany tests GetMany of a storage filled with the mock data.
+// It is shared by the tests of all storage backends.
+func testGetMany(t *testing.T, m MemeStore) {
+	// When.
+	urls, err := m.GetMany([]string{"我就爛", "honest work", "nope"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Want.
+	wantURLs := map[string]string{
+		"我就爛":         imgurBaseLink + "t9WaxTw.png",
+		"honest work": imgurBaseLink + "BPCZHUi.png",
+	}
+	if !reflect.DeepEqual(urls, wantURLs) {
+		t.Errorf("want %v; got %v", wantURLs, urls)
+	}
+}
+
+// testChatSettings tests the per-chat settings of a storage.
+// It is shared by the tests of all storage backends.
+func testChatSettings(t *testing.T, m SettingsStore) {
+	// Default settings.
+	settings, err := m.GetChatSettings("g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings != (ChatSettings{}) {
+		t.Errorf("want default settings; got %v", settings)
+	}
+
+	// Saving twice updates the settings.
+	for _, on := range []bool{true, false, true} {
+		if err = m.SaveChatSettings("g1", ChatSettings{ImplicitTrigger: on}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings, err = m.GetChatSettings("g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !settings.ImplicitTrigger {
+		t.Errorf("want implicit trigger on; got %v", settings)
+	}
+
+	// Other chats are not affected.
+	if settings, _ = m.GetChatSettings("g2"); settings.ImplicitTrigger {
+		t.Errorf("want implicit trigger off; got %v", settings)
+	}
+
+	// All the settings are saved.
+	want := ChatSettings{ImplicitTrigger: true, NoQuickReplies: true, GreetingMeme: "bonjour", FarewellMeme: "adios", MemeCooldown: -1}
+	if err = m.SaveChatSettings("g3", want); err != nil {
+		t.Fatal(err)
+	}
+	if settings, _ = m.GetChatSettings("g3"); settings != want {
+		t.Errorf("want %v; got %v", want, settings)
+	}
+}
+
+// testDiscovery tests Random, Recent and Search of a storage filled with the mock data.
+// It is shared by the tests of all storage backends.
+func testDiscovery(t *testing.T, m MemeStore) {
+	// Random.
+	entry, err := m.Random()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.ID == 0 || entry.Link == "" || len(entry.Aliases) == 0 {
+		t.Errorf("want a meme with aliases; got %v", entry)
+	}
+
+	// Recent.
+	entries, err := m.Recent(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEntries := []MemeEntry{
+		{ID: 4, Link: imgurBaseLink + "BPCZHUi.png", Aliases: []string{"honest work" + nameSuffix, "it ain't much, but it's honest work" + nameSuffix}},
+		{ID: 3, Link: imgurBaseLink + "qg8sB6f.png", Aliases: []string{"bonjour" + nameSuffix}},
+	}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Errorf("want %v; got %v", wantEntries, entries)
+	}
+
+	// Page through all memes.
+	ids := []int{}
+	for cursor := 0; ; {
+		page, err := m.Page(cursor, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, entry := range page {
+			ids = append(ids, entry.ID)
+		}
+		cursor = page[len(page)-1].ID
+	}
+	if want := []int{1, 2, 3, 4}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("want %v; got %v", want, ids)
+	}
+
+	entries, err = m.Page(3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := wantEntries[:1]; !reflect.DeepEqual(entries, want) {
+		t.Errorf("want %v; got %v", want, entries)
+	}
+
+	// Search.
+	scored, err := m.Search("honest", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantNames := []string{"honest work", "it ain't much, but it's honest work"}
+	names := []string{}
+	for _, s := range scored {
+		names = append(names, s.Name)
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("want %v; got %v", wantNames, names)
+	}
+	if len(scored) > 0 && math.Abs(scored[0].Similarity-similarity("honest work", "honest")) > 1e-6 {
+		t.Errorf("want %v; got %v", similarity("honest work", "honest"), scored[0].Similarity)
+	}
+
+	if scored, _ = m.Search("honest", 1); len(scored) != 1 {
+		t.Errorf("want %v; got %v", 1, len(scored))
+	}
+}
+
+// testEvents tests the processed webhook events of a storage.
+// It is shared by the tests of all storage backends.
+func testEvents(t *testing.T, m EventStore) {
+	now := time.Now()
+
+	// Testcases.
+	tests := []struct {
+		testName string
+		eventID  string
+		at       time.Time
+		want     bool
+	}{
+		{"New", "e1", now, true},
+		{"Redelivered", "e1", now.Add(time.Second), false},
+		{"Another", "e2", now.Add(time.Hour), true},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			got, err := m.MarkEvent(tc.eventID, tc.at)
+
+			// Want.
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("want %v; got %v", tc.want, got)
+			}
+		})
+	}
+
+	// Purged events are new again.
+	if err := m.PurgeEvents(now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.MarkEvent("e1", now); !got {
+		t.Error("want purged event e1 to be new")
+	}
+	if got, _ := m.MarkEvent("e2", now); got {
+		t.Error("want event e2 to be kept")
+	}
+}
+
+// testAPIKeys tests the API keys of a storage.
+func testAPIKeys(t *testing.T, m APIKeyStore) {
+	now := time.Now().Truncate(time.Second)
+	for _, key := range []APIKey{
+		{Name: "uploader", Hash: "h1", Scopes: []string{"memes:write"}, CreatedAt: now},
+		{Name: "dashboard", Hash: "h2", Scopes: []string{"stats:read", "memes:read"}, CreatedAt: now},
+	} {
+		if err := m.CreateAPIKey(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Testcases.
+	tests := []struct {
+		testName   string
+		get        func() (APIKey, error)
+		wantName   string
+		wantScopes []string
+		wantErr    error
+	}{
+		{"By name", func() (APIKey, error) { return m.GetAPIKey("dashboard") }, "dashboard", []string{"stats:read", "memes:read"}, nil},
+		{"By hash", func() (APIKey, error) { return m.GetAPIKeyByHash("h1") }, "uploader", []string{"memes:write"}, nil},
+		{"Unknown name", func() (APIKey, error) { return m.GetAPIKey("nobody") }, "", nil, ErrNoRecord},
+		{"Unknown hash", func() (APIKey, error) { return m.GetAPIKeyByHash("h3") }, "", nil, ErrNoRecord},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			got, err := tc.get()
+
+			// Want.
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want error %v; got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if got.Name != tc.wantName || !reflect.DeepEqual(got.Scopes, tc.wantScopes) || !got.CreatedAt.Equal(now) || got.Revoked() {
+				t.Errorf("want %v with %v; got %+v", tc.wantName, tc.wantScopes, got)
+			}
+		})
+	}
+
+	// Names are unique.
+	if err := m.CreateAPIKey(APIKey{Name: "uploader", Hash: "h3", CreatedAt: now}); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("want error %v; got %v", ErrDuplicateKey, err)
+	}
+
+	// Revoked keys are kept, with the first revocation time.
+	if err := m.RevokeAPIKey("uploader", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RevokeAPIKey("uploader", now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RevokeAPIKey("nobody", now); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("want error %v; got %v", ErrNoRecord, err)
+	}
+
+	keys, err := m.ListAPIKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].Name != "dashboard" || keys[1].Name != "uploader" {
+		t.Fatalf("want dashboard and uploader; got %+v", keys)
+	}
+	if keys[0].Revoked() || !keys[1].RevokedAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("want only uploader revoked at %v; got %+v", now.Add(time.Hour), keys)
+	}
+}
+
+// testAuditLog tests the audit log of a storage.
+func testAuditLog(t *testing.T, m AuditStore) {
+	now := time.Now().Truncate(time.Second)
+	for i, e := range []AuditEntry{
+		{Actor: "uploader", Action: "meme.add", MemeName: "ah", NewValue: `{"link":"a.png"}`},
+		{Actor: "uploader", Action: "meme.add", MemeName: "oh", NewValue: `{"link":"o.png"}`},
+		{Actor: "admin", Action: "meme.delete", MemeName: "ah", OldValue: `{"link":"a.png"}`},
+	} {
+		e.ClientIP = "203.0.113.7"
+		e.CreatedAt = now.Add(time.Duration(i) * time.Hour)
+		if err := m.RecordAudit(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Testcases.
+	tests := []struct {
+		testName  string
+		filter    AuditFilter
+		wantNames []string
+	}{
+		{"All", AuditFilter{Limit: 10}, []string{"ah", "oh", "ah"}},
+		{"Limit", AuditFilter{Limit: 2}, []string{"ah", "oh"}},
+		{"Actor", AuditFilter{Actor: "uploader", Limit: 10}, []string{"oh", "ah"}},
+		{"Action", AuditFilter{Action: "meme.delete", Limit: 10}, []string{"ah"}},
+		{"Meme name", AuditFilter{MemeName: "ah", Limit: 10}, []string{"ah", "ah"}},
+		{"Time range", AuditFilter{Since: now.Add(time.Hour), Until: now.Add(2 * time.Hour), Limit: 10}, []string{"oh"}},
+		{"No match", AuditFilter{Actor: "nobody", Limit: 10}, []string{}},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			entries, err := m.AuditLog(tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Want.
+			names := []string{}
+			for _, e := range entries {
+				names = append(names, e.MemeName)
+			}
+			if !reflect.DeepEqual(names, tc.wantNames) {
+				t.Errorf("want %v; got %v", tc.wantNames, names)
+			}
+		})
+	}
+
+	// The entries are paged by ID, and keep every field.
+	first, err := m.AuditLog(AuditFilter{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := m.AuditLog(AuditFilter{Before: first[0].ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next) != 2 || next[0].MemeName != "oh" || next[1].ID >= next[0].ID {
+		t.Errorf("want the 2 older entries; got %+v", next)
+	}
+
+	want := AuditEntry{ID: first[0].ID, Actor: "admin", Action: "meme.delete", MemeName: "ah",
+		OldValue: `{"link":"a.png"}`, ClientIP: "203.0.113.7", CreatedAt: now.Add(2 * time.Hour)}
+	got := first[0]
+	if !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("want created at %v; got %v", want.CreatedAt, got.CreatedAt)
+	}
+	got.CreatedAt = want.CreatedAt
+	if got != want {
+		t.Errorf("want %+v; got %+v", want, got)
+	}
+}
+
+// testTrash tests the trash of a storage filled with the mock data.
+func testTrash(t *testing.T, m MemeStore) {
+	// Stub.
+	if err := m.RecordUsage(Usage{Link: imgurBaseLink + "BPCZHUi.png", Keyword: "honest work", Time: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete("honest work"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Want. The deleted meme is excluded everywhere.
+	if _, err := m.Get("honest work"); err != ErrNoRecord {
+		t.Errorf("Get: want %v; got %v", ErrNoRecord, err)
+	}
+	if _, err := m.GetID("it ain't much, but it's honest work"); err != ErrNoRecord {
+		t.Errorf("GetID: want %v; got %v", ErrNoRecord, err)
+	}
+	if results, _ := m.Search("honest", 10); len(results) != 0 {
+		t.Errorf("Search: want no results; got %v", results)
+	}
+	if got, _ := m.GetMany([]string{"honest work", "我就爛"}); len(got) != 1 {
+		t.Errorf("GetMany: want only 我就爛; got %v", got)
+	}
+	entries, err := m.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Link == imgurBaseLink+"BPCZHUi.png" {
+			t.Errorf("GetAll: want the deleted meme excluded; got %v", e)
+		}
+	}
+	if top, _ := m.TopMemes(time.Time{}, 10); len(top) != 0 {
+		t.Errorf("TopMemes: want no memes; got %v", top)
+	}
+
+	// Want. The names and URL of a meme in the trash are reserved.
+	if err = m.Insert("honest work", "new.png"); err != ErrTrashed {
+		t.Errorf("Insert name: want %v; got %v", ErrTrashed, err)
+	}
+	if err = m.Insert("new", "BPCZHUi.png"); err != ErrTrashed {
+		t.Errorf("Insert URL: want %v; got %v", ErrTrashed, err)
+	}
+
+	trash, err := m.ListTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantAliases := []string{"honest work", "it ain't much, but it's honest work"}
+	if len(trash) != 1 || trash[0].Link != imgurBaseLink+"BPCZHUi.png" || !reflect.DeepEqual(trash[0].Aliases, wantAliases) || trash[0].DeletedAt.IsZero() {
+		t.Fatalf("want the deleted meme in the trash; got %+v", trash)
+	}
+
+	// When.
+	if err = m.Restore("it ain't much, but it's honest work"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Want.
+	if url, err := m.Get("honest work"); err != nil || url != imgurBaseLink+"BPCZHUi.png" {
+		t.Errorf("want %v restored; got %v (err: %v)", imgurBaseLink+"BPCZHUi.png", url, err)
+	}
+	if top, _ := m.TopMemes(time.Time{}, 10); len(top) != 1 {
+		t.Errorf("want the usages restored; got %v", top)
+	}
+	if err = m.Restore("honest work"); err != ErrNoRecord {
+		t.Errorf("want %v restoring a meme not in the trash; got %v", ErrNoRecord, err)
+	}
+
+	// When. Only the memes deleted before the time are purged.
+	if err = m.Delete("honest work"); err != nil {
+		t.Fatal(err)
+	}
+	purged, err := m.PurgeTrash(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 0 {
+		t.Errorf("want nothing purged; got %+v", purged)
+	}
+
+	purged, err = m.PurgeTrash(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Want.
+	if len(purged) != 1 || !reflect.DeepEqual(purged[0].Aliases, wantAliases) {
+		t.Errorf("want the deleted meme purged; got %+v", purged)
+	}
+	if trash, _ = m.ListTrash(); len(trash) != 0 {
+		t.Errorf("want the trash empty; got %+v", trash)
+	}
+	if err = m.Restore("honest work"); err != ErrNoRecord {
+		t.Errorf("want %v restoring a purged meme; got %v", ErrNoRecord, err)
+	}
+	if err = m.Insert("honest work", "BPCZHUi.png"); err != nil {
+		t.Errorf("want the names of a purged meme free; got %v", err)
+	}
+	if top, _ := m.TopMemes(time.Time{}, 10); len(top) != 0 {
+		t.Errorf("want the usages purged; got %v", top)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"testing"
 
+	"github.com/YuChaoGithub/meme-linebot/database"
 	_ "github.com/lib/pq"
 )
 
@@ -22,19 +23,37 @@ func newTestDB(t *testing.T) (*sql.DB, func()) {
 		t.Fatal(err)
 	}
 
-	// Database setup.
-	script, err := ioutil.ReadFile(dbScriptPath + "setup.sql")
+	return db, setupTestDB(t, db, "postgres")
+}
+
+// newTestSQLiteDB returns an in-memory SQLite database connection filled with the mock data,
+// along with its teardown function.
+func newTestSQLiteDB(t *testing.T) (*sql.DB, func()) {
+	// Every connection to ":memory:" opens a new database, so stick to a single connection.
+	db, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
 		t.Fatal(err)
 	}
+	db.SetMaxOpenConns(1)
 
-	_, err = db.Exec(string(script))
+	return db, setupTestDB(t, db, "sqlite")
+}
+
+// setupTestDB migrates the database and inserts the mock data.
+// It returns the teardown function which rolls back all migrations and closes the database.
+func setupTestDB(t *testing.T, db *sql.DB, dialect string) func() {
+	// Database setup.
+	migrator, err := database.NewMigrator(db, dialect)
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	if _, err = migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+
 	// Insert mock data.
-	script, err = ioutil.ReadFile(dbScriptPath + "mock_data.sql")
+	script, err := ioutil.ReadFile(dbScriptPath + "mock_data.sql")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -44,18 +63,35 @@ func newTestDB(t *testing.T) (*sql.DB, func()) {
 		t.Fatal(err)
 	}
 
-	// Return the db and the tear down function.
-	return db, func() {
-		script, err := ioutil.ReadFile(dbScriptPath + "teardown.sql")
-		if err != nil {
+	// Return the tear down function.
+	return func() {
+		if _, err := migrator.Reset(); err != nil {
 			t.Fatal(err)
 		}
 
-		_, err = db.Exec(string(script))
-		if err != nil {
+		db.Close()
+	}
+}
+
+// newTestMemoryModel returns an in-memory meme storage filled with the same mock data as mock_data.sql.
+func newTestMemoryModel(t *testing.T) *MemoryMemeModel {
+	m := NewMemoryMemeModel()
+
+	mockData := []struct {
+		name string
+		url  string
+	}{
+		{"我就爛", "t9WaxTw.png"},
+		{"adios", "6UegMI2.png"},
+		{"bonjour", "qg8sB6f.png"},
+		{"honest work", "BPCZHUi.png"},
+		{"it ain't much, but it's honest work", "BPCZHUi.png"},
+	}
+	for _, d := range mockData {
+		if err := m.Insert(d.name, d.url); err != nil {
 			t.Fatal(err)
 		}
-
-		db.Close()
 	}
+
+	return m
 }
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TrashedMeme is a deleted meme kept in the trash until it is purged.
+type TrashedMeme struct {
+	ID        int       `json:"id"`
+	Link      string    `json:"link"`
+	Aliases   []string  `json:"aliases"` // Sorted, without nameSuffix.
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// ListTrash returns the memes in the trash, most recently deleted first.
+func (m *MemeModel) ListTrash() ([]TrashedMeme, error) {
+	stmt := `SELECT m.id, m.url, m.deleted_at, a.name FROM memes m JOIN aliases a ON a.meme_id = m.id
+	 WHERE m.deleted_at IS NOT NULL ORDER BY m.deleted_at DESC, m.id DESC, a.name ASC`
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTrash(rows)
+}
+
+// Restore moves the meme which the name refers to out of the trash.
+func (m *MemeModel) Restore(name string) error {
+	stmt := `UPDATE memes SET deleted_at = NULL
+	 WHERE deleted_at IS NOT NULL AND id = (SELECT meme_id FROM aliases WHERE name = $1)`
+	res, err := m.DB.Exec(stmt, name)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// PurgeTrash permanently deletes the memes moved to the trash before the time, along with their
+// aliases and usages, and returns them.
+func (m *MemeModel) PurgeTrash(before time.Time) ([]TrashedMeme, error) {
+	var res []TrashedMeme
+
+	err := m.inTx(func(tx *sql.Tx) error {
+		stmt := `SELECT m.id, m.url, m.deleted_at, a.name FROM memes m JOIN aliases a ON a.meme_id = m.id
+		 WHERE m.deleted_at < $1 ORDER BY m.deleted_at DESC, m.id DESC, a.name ASC`
+		rows, err := tx.Query(stmt, before.UTC())
+		if err != nil {
+			return err
+		}
+
+		if res, err = scanTrash(rows); err != nil {
+			return err
+		}
+
+		for _, stmt := range []string{
+			`DELETE FROM usages WHERE meme_id IN (SELECT id FROM memes WHERE deleted_at < $1)`,
+			`DELETE FROM aliases WHERE meme_id IN (SELECT id FROM memes WHERE deleted_at < $1)`,
+			`DELETE FROM memes WHERE deleted_at < $1`,
+		} {
+			if _, err = tx.Exec(stmt, before.UTC()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return res, err
+}
+
+// scanTrash scans and closes the rows of the meme ID, URL, deletion time and alias name, and
+// groups the aliases by meme. A meme appears at the position of its first row.
+func scanTrash(rows *sql.Rows) ([]TrashedMeme, error) {
+	defer rows.Close()
+
+	res := []TrashedMeme{}
+	indices := map[int]int{}
+	for rows.Next() {
+		var id int
+		var url, alias string
+		var deletedAt time.Time
+		if err := rows.Scan(&id, &url, &deletedAt, &alias); err != nil {
+			return res, err
+		}
+
+		i, ok := indices[id]
+		if !ok {
+			i = len(res)
+			indices[id] = i
+			res = append(res, TrashedMeme{ID: id, Link: imgurBaseLink + url, DeletedAt: deletedAt})
+		}
+
+		res[i].Aliases = append(res[i].Aliases, alias)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+// similarity mimics the SIMILARITY function of PostgreSQL's pg_trgm extension.
+// The strings are lowercased and split into words of letters and digits. Each word is padded
+// with two spaces in front and one space behind, and the similarity is the number of shared
+// trigrams divided by the number of distinct trigrams of both strings.
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// trigrams returns the set of trigrams of s.
+func trigrams(s string) map[string]struct{} {
+	res := map[string]struct{}{}
+
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			res[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+
+	return res
+}
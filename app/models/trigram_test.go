@@ -0,0 +1,37 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	// Testcases. The expected values are computed by pg_trgm's SIMILARITY.
+	tests := []struct {
+		testName string
+		a        string
+		b        string
+		wantSim  float64
+	}{
+		{"Identical", "word", "word", 1},
+		{"Case insensitive", "Word", "wORD", 1},
+		{"Suffix", "word", "words", 4.0 / 7.0},
+		{"Punctuations ignored", "it's", "it s", 1},
+		{"Chinese", "就爛", "我就爛", 1.0 / 6.0},
+		{"Nothing in common", "abc", "xyz", 0},
+		{"Empty", "", "abc", 0},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			sim := similarity(tc.a, tc.b)
+
+			// Want.
+			if math.Abs(sim-tc.wantSim) > 1e-9 {
+				t.Errorf("want %v; got %v", tc.wantSim, sim)
+			}
+		})
+	}
+}
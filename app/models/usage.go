@@ -0,0 +1,102 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Source types of a usage, the same as the LINE event source types.
+const (
+	SourceTypeUser  = "user"
+	SourceTypeGroup = "group"
+	SourceTypeRoom  = "room"
+)
+
+// Usage is a record of a meme sent by the bot.
+type Usage struct {
+	Link       string // The image URL as returned by Get and Search.
+	Keyword    string // The keyword received from the user.
+	Fuzzy      bool   // Whether the meme is the closest match of Search rather than an exact one.
+	SourceType string
+	SourceID   string // Hashed ID of the user, group or room.
+	Time       time.Time
+}
+
+// MemeStat is the number of times a meme has been sent.
+type MemeStat struct {
+	MemeID int    `json:"meme_id"`
+	Name   string `json:"name"` // The first alias of the meme.
+	Link   string `json:"link"`
+	Count  int    `json:"count"`
+}
+
+// RecordUsage records a meme sent by the bot.
+func (m *MemeModel) RecordUsage(u Usage) error {
+	var id int
+	stmt := `SELECT id FROM memes WHERE url = $1 AND deleted_at IS NULL`
+	err := m.DB.QueryRow(stmt, strings.TrimPrefix(u.Link, imgurBaseLink)).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO usages (meme_id, keyword, fuzzy, source_type, source_id, created_at)
+	 VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = m.DB.Exec(stmt, id, u.Keyword, u.Fuzzy, u.SourceType, u.SourceID, u.Time.UTC())
+	return err
+}
+
+// TopMemes returns the limit most sent memes since the given time.
+func (m *MemeModel) TopMemes(since time.Time, limit int) ([]MemeStat, error) {
+	stmt := `SELECT m.id, (SELECT MIN(a.name) FROM aliases a WHERE a.meme_id = m.id), m.url, COUNT(*) AS cnt
+	 FROM usages u JOIN memes m ON m.id = u.meme_id
+	 WHERE m.deleted_at IS NULL AND u.created_at >= $1
+	 GROUP BY m.id, m.url ORDER BY cnt DESC, m.id ASC LIMIT $2`
+	return m.queryStats(stmt, since.UTC(), limit)
+}
+
+// TopMemesBySource returns the limit most sent memes in the source (a user, group or room)
+// since the given time. The sourceID is hashed the same way as Usage.SourceID.
+func (m *MemeModel) TopMemesBySource(sourceID string, since time.Time, limit int) ([]MemeStat, error) {
+	stmt := `SELECT m.id, (SELECT MIN(a.name) FROM aliases a WHERE a.meme_id = m.id), m.url, COUNT(*) AS cnt
+	 FROM usages u JOIN memes m ON m.id = u.meme_id
+	 WHERE m.deleted_at IS NULL AND u.source_id = $1 AND u.created_at >= $2
+	 GROUP BY m.id, m.url ORDER BY cnt DESC, m.id ASC LIMIT $3`
+	return m.queryStats(stmt, sourceID, since.UTC(), limit)
+}
+
+// UnusedMemes returns the memes which have never been sent, ordered by ID.
+func (m *MemeModel) UnusedMemes() ([]MemeStat, error) {
+	stmt := `SELECT m.id, (SELECT MIN(a.name) FROM aliases a WHERE a.meme_id = m.id), m.url, 0
+	 FROM memes m WHERE m.deleted_at IS NULL AND NOT EXISTS (SELECT 1 FROM usages u WHERE u.meme_id = m.id)
+	 ORDER BY m.id ASC`
+	return m.queryStats(stmt)
+}
+
+// queryStats runs a statement which selects the meme ID, name, URL and count.
+func (m *MemeModel) queryStats(stmt string, args ...interface{}) ([]MemeStat, error) {
+	res := []MemeStat{}
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		stat := MemeStat{}
+		err = rows.Scan(&stat.MemeID, &stat.Name, &stat.Link, &stat.Count)
+		if err != nil {
+			return res, err
+		}
+
+		stat.Link = imgurBaseLink + stat.Link
+
+		res = append(res, stat)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+const (
+	// maxIdleBuckets is the number of buckets above which the full (idle) ones are dropped.
+	maxIdleBuckets = 10000
+	slowDownText   = "慢一點！梗圖要冷卻一下。\nSlow down! Too many memes."
+)
+
+// tokenBucket holds the tokens of a key. A meme reply takes a token.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time // When the tokens were last refilled.
+	warned bool      // Whether the "slow down" reply is sent since the bucket ran out.
+}
+
+// rateLimiter is a token bucket rate limiter keyed by string. Each bucket holds at most burst
+// tokens and refills rate tokens per second. It is safe for concurrent use. A nil
+// rateLimiter allows everything.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// newRateLimiter returns a rate limiter allowing perMinute replies per minute on average, and
+// at most burst replies at once. It returns nil (no limits) if perMinute is not positive.
+func newRateLimiter(perMinute float64, burst int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:    perMinute / 60,
+		burst:   float64(burst),
+		buckets: map[string]*tokenBucket{},
+		now:     time.Now,
+	}
+}
+
+// check tells whether the key has a token, without taking it. If it has none, warn is true only
+// for the first time since the bucket ran out, so a "slow down" reply is sent once per window.
+func (l *rateLimiter) check(key string) (ok bool, warn bool) {
+	if l == nil {
+		return true, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.bucket(key).check()
+}
+
+// take takes a token of the key, which the caller has checked with check.
+func (l *rateLimiter) take(key string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.bucket(key).take()
+}
+
+// bucket returns the refilled bucket of the key. The caller must hold the lock.
+func (l *rateLimiter) bucket(key string) *tokenBucket {
+	now := l.now()
+	b, found := l.buckets[key]
+	if !found {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.dropIdle(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	l.refill(b, now)
+	return b
+}
+
+// check tells whether the bucket has a token, and whether to warn if it has none.
+func (b *tokenBucket) check() (ok bool, warn bool) {
+	if b.tokens < 1 {
+		warn = !b.warned
+		b.warned = true
+		return false, warn
+	}
+
+	return true, false
+}
+
+// take takes a token of the bucket.
+func (b *tokenBucket) take() {
+	b.tokens--
+	b.warned = false
+}
+
+// refill adds the tokens earned since the last refill. The caller must hold the lock.
+func (l *rateLimiter) refill(b *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+}
+
+// dropIdle removes the full buckets, which behave the same as new ones. The caller must hold
+// the lock.
+func (l *rateLimiter) dropIdle(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// replyLimiter limits the meme replies per user and per group or room.
+type replyLimiter struct {
+	mu            sync.Mutex // Makes checking and taking the tokens of both limiters atomic.
+	users         *rateLimiter
+	chats         *rateLimiter
+	slowDownReply bool
+}
+
+// allow tells whether a meme reply to the source is allowed, and whether to reply with
+// "slow down" instead. The tokens are taken only if both the user and the chat have one.
+func (l *replyLimiter) allow(source *linebot.EventSource) (ok bool, slowDown bool) {
+	if l == nil {
+		return true, false
+	}
+
+	chatID := source.GroupID
+	if chatID == "" {
+		chatID = source.RoomID
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if source.UserID != "" {
+		if ok, warn := l.users.check(source.UserID); !ok {
+			return false, warn && l.slowDownReply
+		}
+	}
+	if chatID != "" {
+		if ok, warn := l.chats.check(chatID); !ok {
+			return false, warn && l.slowDownReply
+		}
+	}
+
+	if source.UserID != "" {
+		l.users.take(source.UserID)
+	}
+	if chatID != "" {
+		l.chats.take(chatID)
+	}
+
+	return true, false
+}
@@ -0,0 +1,177 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestRateLimiter(t *testing.T) {
+	// Stub. 60 per minute is a token per second.
+	l := newRateLimiter(60, 2)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	// Testcases. A token is taken after each successful check, unless noTake.
+	tests := []struct {
+		testName string
+		elapsed  time.Duration
+		key      string
+		noTake   bool
+		wantOK   bool
+		wantWarn bool
+	}{
+		{"Check only", 0, "a", true, true, false},
+		{"Burst 1", 0, "a", false, true, false},
+		{"Burst 2", 0, "a", false, true, false},
+		{"Empty", 0, "a", false, false, true},
+		{"Warn once", 0, "a", false, false, false},
+		{"Other keys", 0, "b", false, true, false},
+		{"Not refilled yet", 500 * time.Millisecond, "a", false, false, false},
+		{"Refilled", 500 * time.Millisecond, "a", false, true, false},
+		{"Empty again", 0, "a", false, false, true},
+		{"At most burst", time.Hour, "a", false, true, false},
+		{"At most burst 2", 0, "a", false, true, false},
+		{"At most burst 3", 0, "a", false, false, true},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			now = now.Add(tc.elapsed)
+
+			// When.
+			ok, warn := l.check(tc.key)
+			if ok && !tc.noTake {
+				l.take(tc.key)
+			}
+
+			// Want.
+			if ok != tc.wantOK || warn != tc.wantWarn {
+				t.Errorf("want %v %v; got %v %v", tc.wantOK, tc.wantWarn, ok, warn)
+			}
+		})
+	}
+
+	// No limits.
+	var none *rateLimiter
+	none.take("a")
+	if ok, _ := none.check("a"); !ok {
+		t.Error("want no limits")
+	}
+}
+
+func TestReplyLimiterConcurrency(t *testing.T) {
+	// Stub. No refills during the test.
+	l := &replyLimiter{
+		users: newRateLimiter(0.001, 1),
+		chats: newRateLimiter(0.001, 50),
+	}
+
+	// When. Each user sends one meme to the group.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			source := &linebot.EventSource{Type: linebot.EventSourceTypeGroup, UserID: fmt.Sprint("u", i), GroupID: "group"}
+			if ok, _ := l.allow(source); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Want.
+	if allowed != 50 {
+		t.Errorf("want %d; got %d", 50, allowed)
+	}
+}
+
+func TestReplyLimiter(t *testing.T) {
+	// Stub.
+	l := &replyLimiter{
+		users:         newRateLimiter(0.001, 2),
+		chats:         newRateLimiter(0.001, 3),
+		slowDownReply: true,
+	}
+	source := func(userID, groupID string) *linebot.EventSource {
+		return &linebot.EventSource{Type: linebot.EventSourceTypeGroup, UserID: userID, GroupID: groupID}
+	}
+
+	// Testcases.
+	tests := []struct {
+		testName     string
+		source       *linebot.EventSource
+		wantOK       bool
+		wantSlowDown bool
+	}{
+		{"User 1", source("u1", "g1"), true, false},
+		{"User 2", source("u1", "g1"), true, false},
+		{"User limit", source("u1", "g1"), false, true},
+		{"Another user", source("u2", "g1"), true, false},
+		{"Group limit", source("u3", "g1"), false, true},
+		{"Another group", source("u3", "g2"), true, false},
+		{"Not taken when limited", source("u3", "g2"), true, false},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			ok, slowDown := l.allow(tc.source)
+
+			// Want.
+			if ok != tc.wantOK || slowDown != tc.wantSlowDown {
+				t.Errorf("want %v %v; got %v %v", tc.wantOK, tc.wantSlowDown, ok, slowDown)
+			}
+		})
+	}
+
+	// No limits.
+	var none *replyLimiter
+	if ok, _ := none.allow(source("u1", "g1")); !ok {
+		t.Error("want no limits")
+	}
+}
+
+func TestReplyLimitedBeforeLookup(t *testing.T) {
+	// Stub. A fake LINE API, and a user who can get one meme.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	a := newTestApp(t)
+	a.memeModel = newTimedStore(a.memeModel.(store), a.metrics)
+	a.limiter = &replyLimiter{users: newRateLimiter(0.001, 1)}
+	bot, err := linebot.New("secret", "token", linebot.WithEndpointBase(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.bot = bot
+	a.usages = newUsageRecorder(a.memeModel, a.logger)
+	source := &linebot.EventSource{Type: linebot.EventSourceTypeUser, UserID: "u1"}
+
+	// When.
+	for i := 0; i < 3; i++ {
+		a.replyWithMeme(context.Background(), "token", source, "我就爛.jpg")
+	}
+	a.usages.close()
+
+	// Want. The limited messages are not looked up.
+	if want := `memebot_db_query_duration_seconds_count{method="GetMany"} 1`; !strings.Contains(scrape(t, a.routes()), want) {
+		t.Errorf("want metrics to contain %q", want)
+	}
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxKeywordLength is the longest keyword in runes, the same as the name column.
+	maxKeywordLength = 128
+)
+
+// validSuffixes are checked in order, so a suffix must come before its own prefixes.
+var validSuffixes = []string{".jpeg", ".jpg", ".png", ".gif"}
+var punctuations = map[rune]struct{}{
+	'?': {}, '!': {}, '.': {}, ',': {}, '\'': {}, ';': {}, ':': {}, '-': {}, '(': {}, ')': {}, '"': {},
+	'。': {}, '，': {}, '！': {}, '？': {}, '、': {}, '：': {}, '；': {}, '）': {}, '（': {},
+	'‘': {}, '’': {}, '“': {}, '”': {},
+}
+
+// scanKeywords finds every "<keyword>.<ext>" occurrence in the message. Since a keyword may
+// contain spaces, and CJK text has no spaces between words at all, the exact boundary of a
+// keyword cannot be told from the message alone. So for each occurrence, it returns the
+// possible keywords ending at the suffix, longest first, with punctuations stripped.
+// The first candidate is the whole text between the previous occurrence and the suffix.
+func scanKeywords(message string) [][]string {
+	res := [][]string{}
+
+	text := []rune(strings.ToLower(message))
+	start := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] != '.' {
+			continue
+		}
+
+		suffix := matchSuffix(text[i:])
+		if suffix == 0 {
+			continue
+		}
+
+		// A new line also ends a keyword.
+		segmentStart := start
+		for j := i - 1; j >= start; j-- {
+			if text[j] == '\n' {
+				segmentStart = j + 1
+				break
+			}
+		}
+
+		if candidates := keywordCandidates(text[segmentStart:i]); len(candidates) > 0 {
+			res = append(res, candidates)
+		}
+
+		i += suffix - 1
+		start = i + 1
+	}
+
+	return res
+}
+
+// matchSuffix returns the length of the valid suffix at the beginning of text, or 0 if there
+// is none. The suffix must not be followed by a letter or digit (e.g. ".jpgs").
+func matchSuffix(text []rune) int {
+	for _, suffix := range validSuffixes {
+		n := len(suffix)
+		if len(text) < n || string(text[:n]) != suffix {
+			continue
+		}
+
+		if len(text) > n && isASCIIAlnum(text[n]) {
+			return 0
+		}
+
+		return n
+	}
+
+	return 0
+}
+
+// keywordCandidates returns the cleaned keywords which may end at the end of segment, longest
+// first. A keyword starts at the beginning of a word, or at any CJK character.
+func keywordCandidates(segment []rune) []string {
+	if len(segment) > maxKeywordLength {
+		segment = segment[len(segment)-maxKeywordLength:]
+	}
+
+	res := []string{}
+	seen := map[string]struct{}{}
+	for i := range segment {
+		if i > 0 && !isBoundary(segment[i-1], segment[i]) {
+			continue
+		}
+
+		candidate := cleanKeyword(segment[i:])
+		if _, ok := seen[candidate]; ok || candidate == "" {
+			continue
+		}
+
+		seen[candidate] = struct{}{}
+		res = append(res, candidate)
+	}
+
+	return res
+}
+
+// isBoundary tells whether a keyword can start at cur, which follows prev.
+func isBoundary(prev, cur rune) bool {
+	if unicode.IsSpace(prev) || isCJK(prev) || isCJK(cur) {
+		return true
+	}
+
+	_, ok := punctuations[prev]
+	return ok
+}
+
+// cleanKeyword trims the spaces and gets rid of the punctuations.
+func cleanKeyword(keyword []rune) string {
+	cleaned := []rune{}
+	for _, val := range keyword {
+		if _, ok := punctuations[val]; !ok {
+			cleaned = append(cleaned, val)
+		}
+	}
+
+	return strings.TrimSpace(string(cleaned))
+}
+
+// trimSuffix removes a valid suffix from the end of the meme name, if any.
+func trimSuffix(name string) string {
+	for _, suffix := range validSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSpace(strings.TrimSuffix(name, suffix))
+		}
+	}
+
+	return name
+}
+
+// isCJK tells whether r belongs to a script written without spaces between words.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+func isASCIIAlnum(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
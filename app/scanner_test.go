@@ -0,0 +1,39 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanKeywords(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName       string
+		message        string
+		wantCandidates [][]string
+	}{
+		{"Whole message", "我就爛.jpg", [][]string{{"我就爛", "就爛", "爛"}}},
+		{"Trailing spaces and case", "  Honest Work.JPG ", [][]string{{"honest work", "work"}}},
+		{"Punctuations", "It ain't much!.png", [][]string{{"it aint much", "aint much", "t much", "much"}}},
+		{"In a sentence", "lol 我就爛.jpg haha", [][]string{{"lol 我就爛", "我就爛", "就爛", "爛"}}},
+		{"CJK without spaces", "哈哈我就爛.gif", [][]string{{"哈哈我就爛", "哈我就爛", "我就爛", "就爛", "爛"}}},
+		{"Two memes", "adios.jpg bonjour.jpeg", [][]string{{"adios"}, {"bonjour"}}},
+		{"New line", "hello\nadios.jpg", [][]string{{"adios"}}},
+		{"Not a suffix", "adios.jpgs and this.txt", [][]string{}},
+		{"No keyword", ".jpg", [][]string{}},
+		{"No suffix", "我就爛", [][]string{}},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			candidates := scanKeywords(tc.message)
+
+			// Want.
+			if !reflect.DeepEqual(candidates, tc.wantCandidates) {
+				t.Errorf("want %q; got %q", tc.wantCandidates, candidates)
+			}
+		})
+	}
+}
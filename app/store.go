@@ -0,0 +1,187 @@
+package app
+
+import (
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// store is a meme storage, which both MemeModel and MemoryMemeModel are.
+type store interface {
+	models.MemeStore
+	models.SettingsStore
+	models.EventStore
+	models.APIKeyStore
+	models.AuditStore
+}
+
+// timedStore observes the latency of each method of the wrapped storage.
+type timedStore struct {
+	store    store
+	duration *prometheus.HistogramVec
+}
+
+func newTimedStore(s store, m *metrics) *timedStore {
+	return &timedStore{store: s, duration: m.queryDuration}
+}
+
+// observe records the time since start. Call it deferred at the start of a method.
+func (s *timedStore) observe(method string, start time.Time) {
+	s.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func (s *timedStore) GetAll() ([]models.MemeEntry, error) {
+	defer s.observe("GetAll", time.Now())
+	return s.store.GetAll()
+}
+
+func (s *timedStore) Random() (models.MemeEntry, error) {
+	defer s.observe("Random", time.Now())
+	return s.store.Random()
+}
+
+func (s *timedStore) Recent(limit int) ([]models.MemeEntry, error) {
+	defer s.observe("Recent", time.Now())
+	return s.store.Recent(limit)
+}
+
+func (s *timedStore) Page(cursor int, limit int) ([]models.MemeEntry, error) {
+	defer s.observe("Page", time.Now())
+	return s.store.Page(cursor, limit)
+}
+
+func (s *timedStore) Get(name string) (string, error) {
+	defer s.observe("Get", time.Now())
+	return s.store.Get(name)
+}
+
+func (s *timedStore) GetMany(names []string) (map[string]string, error) {
+	defer s.observe("GetMany", time.Now())
+	return s.store.GetMany(names)
+}
+
+func (s *timedStore) GetID(name string) (int, error) {
+	defer s.observe("GetID", time.Now())
+	return s.store.GetID(name)
+}
+
+func (s *timedStore) Search(query string, limit int) ([]models.ScoredMeme, error) {
+	defer s.observe("Search", time.Now())
+	return s.store.Search(query, limit)
+}
+
+func (s *timedStore) Insert(name string, url string) error {
+	defer s.observe("Insert", time.Now())
+	return s.store.Insert(name, url)
+}
+
+func (s *timedStore) Delete(name string) error {
+	defer s.observe("Delete", time.Now())
+	return s.store.Delete(name)
+}
+
+func (s *timedStore) ListTrash() ([]models.TrashedMeme, error) {
+	defer s.observe("ListTrash", time.Now())
+	return s.store.ListTrash()
+}
+
+func (s *timedStore) Restore(name string) error {
+	defer s.observe("Restore", time.Now())
+	return s.store.Restore(name)
+}
+
+func (s *timedStore) PurgeTrash(before time.Time) ([]models.TrashedMeme, error) {
+	defer s.observe("PurgeTrash", time.Now())
+	return s.store.PurgeTrash(before)
+}
+
+func (s *timedStore) AddAlias(memeID int, name string) error {
+	defer s.observe("AddAlias", time.Now())
+	return s.store.AddAlias(memeID, name)
+}
+
+func (s *timedStore) RemoveAlias(name string) error {
+	defer s.observe("RemoveAlias", time.Now())
+	return s.store.RemoveAlias(name)
+}
+
+func (s *timedStore) ListAliases(memeID int) ([]string, error) {
+	defer s.observe("ListAliases", time.Now())
+	return s.store.ListAliases(memeID)
+}
+
+func (s *timedStore) RecordUsage(u models.Usage) error {
+	defer s.observe("RecordUsage", time.Now())
+	return s.store.RecordUsage(u)
+}
+
+func (s *timedStore) TopMemes(since time.Time, limit int) ([]models.MemeStat, error) {
+	defer s.observe("TopMemes", time.Now())
+	return s.store.TopMemes(since, limit)
+}
+
+func (s *timedStore) TopMemesBySource(sourceID string, since time.Time, limit int) ([]models.MemeStat, error) {
+	defer s.observe("TopMemesBySource", time.Now())
+	return s.store.TopMemesBySource(sourceID, since, limit)
+}
+
+func (s *timedStore) UnusedMemes() ([]models.MemeStat, error) {
+	defer s.observe("UnusedMemes", time.Now())
+	return s.store.UnusedMemes()
+}
+
+func (s *timedStore) GetChatSettings(chatID string) (models.ChatSettings, error) {
+	defer s.observe("GetChatSettings", time.Now())
+	return s.store.GetChatSettings(chatID)
+}
+
+func (s *timedStore) SaveChatSettings(chatID string, settings models.ChatSettings) error {
+	defer s.observe("SaveChatSettings", time.Now())
+	return s.store.SaveChatSettings(chatID, settings)
+}
+
+func (s *timedStore) MarkEvent(eventID string, at time.Time) (bool, error) {
+	defer s.observe("MarkEvent", time.Now())
+	return s.store.MarkEvent(eventID, at)
+}
+
+func (s *timedStore) PurgeEvents(before time.Time) error {
+	defer s.observe("PurgeEvents", time.Now())
+	return s.store.PurgeEvents(before)
+}
+
+func (s *timedStore) CreateAPIKey(key models.APIKey) error {
+	defer s.observe("CreateAPIKey", time.Now())
+	return s.store.CreateAPIKey(key)
+}
+
+func (s *timedStore) GetAPIKey(name string) (models.APIKey, error) {
+	defer s.observe("GetAPIKey", time.Now())
+	return s.store.GetAPIKey(name)
+}
+
+func (s *timedStore) GetAPIKeyByHash(hash string) (models.APIKey, error) {
+	defer s.observe("GetAPIKeyByHash", time.Now())
+	return s.store.GetAPIKeyByHash(hash)
+}
+
+func (s *timedStore) ListAPIKeys() ([]models.APIKey, error) {
+	defer s.observe("ListAPIKeys", time.Now())
+	return s.store.ListAPIKeys()
+}
+
+func (s *timedStore) RevokeAPIKey(name string, at time.Time) error {
+	defer s.observe("RevokeAPIKey", time.Now())
+	return s.store.RevokeAPIKey(name, at)
+}
+
+func (s *timedStore) RecordAudit(e models.AuditEntry) error {
+	defer s.observe("RecordAudit", time.Now())
+	return s.store.RecordAudit(e)
+}
+
+func (s *timedStore) AuditLog(f models.AuditFilter) ([]models.AuditEntry, error) {
+	defer s.observe("AuditLog", time.Now())
+	return s.store.AuditLog(f)
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"strings"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+const (
+	// confidentSimilarity is the lowest similarity for a fuzzy match to be sent directly.
+	// Below it, the closest names are suggested instead of sending a possibly wrong meme.
+	confidentSimilarity      = 0.3
+	maxSuggestionsPerKeyword = 3
+	// maxSuggestions keeps the text within the 5000 characters LINE allows in a text message,
+	// since a name has at most maxKeywordLength runes.
+	maxSuggestions     = maxQuickReplyItems
+	maxQuickReplyItems = 13 // LINE allows at most 13 quick reply buttons.
+	maxQuickReplyLabel = 20 // LINE allows at most 20 characters in a button label.
+	didYouMeanText     = "你是不是要找 Did you mean: "
+)
+
+// suggestionMessage returns a "did you mean" text message listing at most maxSuggestions of the
+// meme names, with quick reply buttons which send the names as keywords.
+func suggestionMessage(names []string) linebot.SendingMessage {
+	if len(names) > maxSuggestions {
+		names = names[:maxSuggestions]
+	}
+
+	keywords := keywordsOf(names)
+	return linebot.NewTextMessage(didYouMeanText + strings.Join(keywords, ", ")).
+		WithQuickReplies(quickReplies(keywords))
+}
+
+// quickReplies returns the quick reply buttons which send the keywords, at most
+// maxQuickReplyItems of them.
+func quickReplies(keywords []string) *linebot.QuickReplyItems {
+	if len(keywords) > maxQuickReplyItems {
+		keywords = keywords[:maxQuickReplyItems]
+	}
+
+	buttons := make([]*linebot.QuickReplyButton, len(keywords))
+	for i, keyword := range keywords {
+		buttons[i] = linebot.NewQuickReplyButton("", linebot.NewMessageAction(quickReplyLabel(keyword), keyword))
+	}
+
+	return linebot.NewQuickReplyItems(buttons...)
+}
+
+// nearestNames returns the closest names of the fuzzy matches, without duplicates.
+func nearestNames(matches []memeMatch) []string {
+	res := []string{}
+	seen := map[string]struct{}{}
+	for _, match := range matches {
+		for _, name := range match.nearest {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				res = append(res, name)
+			}
+		}
+	}
+
+	return res
+}
+
+// quickRepliesEnabled tells whether the chat wants the quick reply suggestions on fuzzy matches.
+func (a *App) quickRepliesEnabled(ctx context.Context, source *linebot.EventSource) bool {
+	settings, ok := a.chatSettings(ctx, source)
+	return ok && !settings.NoQuickReplies
+}
+
+// keywordsOf returns the names with the keyword suffix, ready to be sent as keywords.
+func keywordsOf(names []string) []string {
+	res := make([]string, len(names))
+	for i, name := range names {
+		res[i] = name + keywordSuffix
+	}
+
+	return res
+}
+
+// quickReplyLabel shortens the text to fit in a button label.
+func quickReplyLabel(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxQuickReplyLabel {
+		return text
+	}
+
+	return string(runes[:maxQuickReplyLabel-1]) + "…"
+}
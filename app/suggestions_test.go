@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestSuggestionMessage(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName    string
+		names       []string
+		wantButtons int
+		wantLabel   string // The label of the first button.
+		wantText    string
+	}{
+		{"One", []string{"我就爛"}, 1, "我就爛.jpg", didYouMeanText + "我就爛.jpg"},
+		{"Long label", []string{strings.Repeat("爛", 30)}, 1, strings.Repeat("爛", 19) + "…", didYouMeanText + strings.Repeat("爛", 30) + ".jpg"},
+		{"At most 13", strings.Split("a b c d e f g h i j k l m n o", " "), 13, "a.jpg", didYouMeanText + "a.jpg, b.jpg, c.jpg, d.jpg, e.jpg, f.jpg, g.jpg, h.jpg, i.jpg, j.jpg, k.jpg, l.jpg, m.jpg"},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			b, err := json.Marshal(suggestionMessage(tc.names))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Want.
+			res := struct {
+				Text       string `json:"text"`
+				QuickReply struct {
+					Items []struct {
+						Action struct {
+							Label string `json:"label"`
+							Text  string `json:"text"`
+						} `json:"action"`
+					} `json:"items"`
+				} `json:"quickReply"`
+			}{}
+			if err = json.Unmarshal(b, &res); err != nil {
+				t.Fatal(err)
+			}
+
+			if res.Text != tc.wantText {
+				t.Errorf("want text %q; got %q", tc.wantText, res.Text)
+			}
+
+			items := res.QuickReply.Items
+			if len(items) != tc.wantButtons {
+				t.Fatalf("want %d buttons; got %d", tc.wantButtons, len(items))
+			}
+			if items[0].Action.Label != tc.wantLabel {
+				t.Errorf("want label %q; got %q", tc.wantLabel, items[0].Action.Label)
+			}
+			if items[0].Action.Text != tc.names[0]+keywordSuffix {
+				t.Errorf("want text %q; got %q", tc.names[0]+keywordSuffix, items[0].Action.Text)
+			}
+		})
+	}
+}
+
+func TestFuzzyQuickReplies(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+	for _, name := range []string{"bonjour", "bonjour madame"} {
+		if err := a.memeModel.Insert(name, name+".png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	event := &linebot.Event{Source: &linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: "group"}}
+
+	// When.
+	matches, _ := a.findMemes(context.Background(), "bonjer.jpg")
+
+	// Want.
+	if len(matches) != 1 || !matches[0].fuzzy {
+		t.Fatalf("want a fuzzy match; got %v", matches)
+	}
+	if want := []string{"bonjour", "bonjour madame"}; !reflect.DeepEqual(nearestNames(matches), want) {
+		t.Errorf("want nearest names %v; got %v", want, nearestNames(matches))
+	}
+
+	// Quick replies are on by default, and can be turned off and on per chat.
+	for _, tc := range []struct {
+		command string
+		want    bool
+	}{
+		{"", true},
+		{"/quickreply off", false},
+		{"/quickreply on", true},
+	} {
+		if tc.command != "" {
+			a.commands.dispatch(context.Background(), event, tc.command)
+		}
+		if got := a.quickRepliesEnabled(context.Background(), event.Source); got != tc.want {
+			t.Errorf("after %q: want quick replies %v; got %v", tc.command, tc.want, got)
+		}
+	}
+}
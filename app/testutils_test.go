@@ -0,0 +1,61 @@
+package app
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+)
+
+const (
+	testAdminSecret = "secret"
+	testKeyPepper   = "pepper"
+)
+
+// newTestApp returns an app backed by the in-memory meme storage.
+func newTestApp(t *testing.T) *App {
+	memeModel := models.NewMemoryMemeModel()
+	if err := memeModel.Insert("我就爛", "t9WaxTw.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	pageTemplates, err := newTemplateCache([]string{"../ui/html/home.html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &App{
+		adminSecret:     testAdminSecret,
+		keyPepper:       testKeyPepper,
+		signatureMaxAge: 5 * time.Minute,
+		signatures:      newSignatureCache(5 * time.Minute),
+		apiKeys:         memeModel,
+		audits:          memeModel,
+		greetingMeme:    "bonjour",
+		farewellMeme:    "adios",
+		memeCooldown:    10 * time.Second,
+		cooldowns:       newCooldownCache(),
+		memeModel:       memeModel,
+		settings:        memeModel,
+		pageTemplates:   pageTemplates,
+		metrics:         newMetrics(),
+		logger:          slog.Default(),
+		hashLogIDs:      true,
+	}
+	a.storageReady.Store(true)
+	a.registerCommands()
+	a.registerEvents()
+
+	return a
+}
+
+// newAdminRequest returns a POST request to an admin API, authenticated with the bearer key.
+func newAdminRequest(target, key, body string) *http.Request {
+	r := httptest.NewRequest("POST", target, strings.NewReader(body))
+	r.Header.Set("Authorization", bearerScheme+" "+key)
+	return r
+}
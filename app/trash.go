@@ -0,0 +1,115 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+)
+
+// listTrash is used by the admin to list the deleted memes, most recently deleted first, along
+// with when they can be purged.
+func (a *App) listTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		return
+	}
+
+	// Query the database.
+	trash, err := a.memeModel.ListTrash()
+	if err != nil {
+		a.log(r.Context()).Error("fetching the trash", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	type trashedMeme struct {
+		models.TrashedMeme
+		PurgeAfter time.Time `json:"purge_after"`
+	}
+	res := struct {
+		Trash []trashedMeme `json:"trash"`
+	}{Trash: []trashedMeme{}}
+	for _, meme := range trash {
+		res.Trash = append(res.Trash, trashedMeme{meme, meme.DeletedAt.Add(a.trashRetention)})
+	}
+
+	// Success.
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// restoreMeme is used by the admin to move a deleted meme out of the trash.
+func (a *App) restoreMeme(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		return
+	}
+
+	// Retrieve the request body.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		a.log(r.Context()).Error("reading the request body", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Unmarshal json.
+	req := struct {
+		Name string `json:"name"`
+	}{}
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Restore in the database.
+	err = a.memeModel.Restore(req.Name)
+	if errors.Is(err, models.ErrNoRecord) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		a.log(r.Context()).Error("restoring the meme", "name", req.Name, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	newValue := ""
+	if id, err := a.memeModel.GetID(req.Name); err == nil {
+		newValue = a.snapshot(r.Context(), id)
+	}
+	a.audit(r, auditMemeRestore, req.Name, "", newValue)
+
+	// Success.
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeTrash is used by the admin to permanently delete the memes which have been in the trash
+// for longer than the retention period.
+func (a *App) purgeTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		return
+	}
+
+	// Purge from the database.
+	purged, err := a.memeModel.PurgeTrash(time.Now().Add(-a.trashRetention))
+	if err != nil {
+		a.log(r.Context()).Error("purging the trash", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	for _, meme := range purged {
+		oldValue, _ := json.Marshal(memeSnapshot{Link: meme.Link, Aliases: meme.Aliases})
+		a.audit(r, auditMemePurge, meme.Aliases[0], string(oldValue), "")
+	}
+	a.log(r.Context()).Info("purged the trash", "count", len(purged))
+
+	// Success.
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Purged []models.TrashedMeme `json:"purged"`
+	}{purged})
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+)
+
+func TestTrash(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+	a.trashRetention = time.Hour
+	handler := a.routes()
+
+	// Testcases. They run in order against the same app.
+	tests := []struct {
+		testName   string
+		target     string
+		body       string
+		wantStatus int
+	}{
+		{"Delete", "/delete", `{"name": "我就爛"}`, http.StatusNoContent},
+		{"Add a deleted name", "/add", `{"name": "我就爛", "link": "new.png"}`, http.StatusConflict},
+		{"Restore", "/trash/restore", `{"name": "我就爛"}`, http.StatusNoContent},
+		{"Restore again", "/trash/restore", `{"name": "我就爛"}`, http.StatusNotFound},
+		{"Delete again", "/delete", `{"name": "我就爛"}`, http.StatusNoContent},
+		{"Purge within retention", "/trash/purge", `{}`, http.StatusOK},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		// When.
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newAdminRequest(tc.target, testAdminSecret, tc.body))
+
+		// Want.
+		if rr.Code != tc.wantStatus {
+			t.Fatalf("%v: want %v; got %v", tc.testName, tc.wantStatus, rr.Code)
+		}
+	}
+
+	// When.
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newAdminRequest("/trash", testAdminSecret, `{}`))
+
+	// Want. The meme is kept in the trash until the retention period is over.
+	res := struct {
+		Trash []struct {
+			models.TrashedMeme
+			PurgeAfter time.Time `json:"purge_after"`
+		} `json:"trash"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Trash) != 1 || res.Trash[0].Aliases[0] != "我就爛" || !res.Trash[0].PurgeAfter.Equal(res.Trash[0].DeletedAt.Add(time.Hour)) {
+		t.Fatalf("want 我就爛 in the trash for an hour; got %+v", res.Trash)
+	}
+
+	// When. The retention period is over.
+	a.trashRetention = 0
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newAdminRequest("/trash/purge", testAdminSecret, `{}`))
+
+	// Want.
+	purged := struct {
+		Purged []models.TrashedMeme `json:"purged"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &purged); err != nil {
+		t.Fatal(err)
+	}
+	if len(purged.Purged) != 1 || purged.Purged[0].Link != "https://i.imgur.com/t9WaxTw.png" {
+		t.Errorf("want 我就爛 purged; got %+v", purged.Purged)
+	}
+
+	entries, err := a.audits.AuditLog(models.AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantActions := []string{auditMemePurge, auditMemeDelete, auditMemeRestore, auditMemeDelete}
+	if len(entries) != len(wantActions) {
+		t.Fatalf("want %v; got %+v", wantActions, entries)
+	}
+	for i, e := range entries {
+		if e.Action != wantActions[i] || e.MemeName != "我就爛" {
+			t.Errorf("entry %d: want %v of 我就爛; got %+v", i, wantActions[i], e)
+		}
+	}
+
+	// Want. The name is free again.
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newAdminRequest("/add", testAdminSecret, `{"name": "我就爛", "link": "new.png"}`))
+	if rr.Code != http.StatusCreated {
+		t.Errorf("want %v; got %v", http.StatusCreated, rr.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+const usageQueueSize = 256
+
+// usageRecorder records the meme usages in the background so that the webhook is not slowed
+// down by the database. Usages are dropped when the queue is full.
+type usageRecorder struct {
+	store  models.MemeStore
+	queue  chan models.Usage
+	done   chan struct{}
+	logger *slog.Logger
+}
+
+// newUsageRecorder returns a usage recorder with its background goroutine started.
+func newUsageRecorder(store models.MemeStore, logger *slog.Logger) *usageRecorder {
+	r := &usageRecorder{
+		store:  store,
+		queue:  make(chan models.Usage, usageQueueSize),
+		done:   make(chan struct{}),
+		logger: logger,
+	}
+
+	go func() {
+		defer close(r.done)
+		for u := range r.queue {
+			if err := r.store.RecordUsage(u); err != nil {
+				r.logger.Error("recording the meme usage", "link", u.Link, "error", err)
+			}
+		}
+	}()
+
+	return r
+}
+
+// record queues the usage without blocking.
+func (r *usageRecorder) record(u models.Usage) {
+	select {
+	case r.queue <- u:
+	default:
+		r.logger.Warn("usage queue is full, dropping the usage", "link", u.Link)
+	}
+}
+
+// close stops accepting usages and waits for the queued ones to be recorded.
+func (r *usageRecorder) close() {
+	close(r.queue)
+	<-r.done
+}
+
+// sourceOf returns the source type and the hashed source ID of the event source.
+// For groups and rooms, the ID is the group or room rather than the sending user.
+func (a *App) sourceOf(source *linebot.EventSource) (string, string) {
+	switch source.Type {
+	case linebot.EventSourceTypeGroup:
+		return models.SourceTypeGroup, a.hashSourceID(source.GroupID)
+	case linebot.EventSourceTypeRoom:
+		return models.SourceTypeRoom, a.hashSourceID(source.RoomID)
+	default:
+		return models.SourceTypeUser, a.hashSourceID(source.UserID)
+	}
+}
+
+// hashSourceID hashes a LINE user, group or room ID with the secret salt, so that the
+// statistics cannot be traced back to the chat.
+func (a *App) hashSourceID(id string) string {
+	mac := hmac.New(sha256.New, []byte(a.statsHashSalt))
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
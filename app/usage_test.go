@@ -0,0 +1,70 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestUsageRecorder(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+	r := newUsageRecorder(a.memeModel, a.logger)
+
+	// When.
+	sourceType, sourceID := a.sourceOf(&linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: "G1", UserID: "U1"})
+	r.record(models.Usage{Link: "https://i.imgur.com/t9WaxTw.png", Keyword: "我就爛", SourceType: sourceType, SourceID: sourceID, Time: time.Now()})
+	r.close()
+
+	// Want.
+	if sourceType != models.SourceTypeGroup || sourceID != a.hashSourceID("G1") || sourceID == "G1" {
+		t.Errorf("want hashed group source; got %v %v", sourceType, sourceID)
+	}
+
+	stats, err := a.memeModel.TopMemesBySource(a.hashSourceID("G1"), time.Time{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Count != 1 {
+		t.Errorf("want 1 recorded usage; got %v", stats)
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+
+	// When.
+	rr := httptest.NewRecorder()
+	a.routes().ServeHTTP(rr, newAdminRequest("/stats", testAdminSecret, `{"days": 7}`))
+
+	// Want.
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want %v; got %v", http.StatusOK, rr.Code)
+	}
+
+	res := struct {
+		Top    []models.MemeStat `json:"top"`
+		Unused []models.MemeStat `json:"unused"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Top) != 0 || len(res.Unused) != 1 {
+		t.Errorf("want 0 top and 1 unused memes; got %v", res)
+	}
+
+	// When.
+	rr = httptest.NewRecorder()
+	a.routes().ServeHTTP(rr, newAdminRequest("/stats", "guess", `{}`))
+
+	// Want.
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("want %v; got %v", http.StatusUnauthorized, rr.Code)
+	}
+}
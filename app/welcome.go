@@ -0,0 +1,153 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/YuChaoGithub/meme-linebot/app/models"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+const (
+	welcomeText = "嗨！我是梗圖藏家。在訊息中提到梗圖名稱加上 .jpg（例如「我就爛.jpg」），我就會回覆梗圖。輸入 /help 查看所有指令。\n" +
+		"Hi! I'm Meme Collector. Mention a meme name with .jpg (e.g. \"我就爛.jpg\") and I'll reply with the meme. Send /help for all commands."
+	greetingUsage       = "用法 Usage: /greeting <梗圖名稱 meme name>|reset"
+	farewellUsage       = "用法 Usage: /farewell <梗圖名稱 meme name>|reset"
+	memeSettingReset    = "reset"
+	memeSettingSetReply = "已設定為 Set to: "
+	memeSettingNotFound = "找不到梗圖 Meme not found: "
+)
+
+// onWelcome explains the usage when a user adds the bot as a friend, or when the bot joins a
+// group or room.
+func (a *App) onWelcome(ctx context.Context, event *linebot.Event) {
+	a.replyWithNamedMeme(ctx, event.ReplyToken, event.Source, a.greetingOf(ctx, event.Source), linebot.NewTextMessage(welcomeText))
+}
+
+// onMemberJoined greets the new members of a group or room.
+func (a *App) onMemberJoined(ctx context.Context, event *linebot.Event) {
+	a.replyWithNamedMeme(ctx, event.ReplyToken, event.Source, a.greetingOf(ctx, event.Source))
+}
+
+// onMemberLeft says goodbye to the members leaving a group or room.
+func (a *App) onMemberLeft(ctx context.Context, event *linebot.Event) {
+	a.replyWithNamedMeme(ctx, event.ReplyToken, event.Source, a.farewellOf(ctx, event.Source))
+}
+
+// memeName returns the name of the meme given as "我就爛.jpg" or "我就爛", as it is stored.
+func memeName(s string) string {
+	return trimSuffix(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// greetingOf returns the name of the greeting meme of the chat.
+func (a *App) greetingOf(ctx context.Context, source *linebot.EventSource) string {
+	if settings, ok := a.chatSettings(ctx, source); ok && settings.GreetingMeme != "" {
+		return settings.GreetingMeme
+	}
+
+	return a.greetingMeme
+}
+
+// farewellOf returns the name of the farewell meme of the chat.
+func (a *App) farewellOf(ctx context.Context, source *linebot.EventSource) string {
+	if settings, ok := a.chatSettings(ctx, source); ok && settings.FarewellMeme != "" {
+		return settings.FarewellMeme
+	}
+
+	return a.farewellMeme
+}
+
+// chatKey returns the key of the settings of the chat, which is the LINE group or room ID, or
+// else the user ID. Unlike the ID of sourceOf, it does not depend on the statistics salt, so
+// changing the salt keeps the settings.
+func chatKey(source *linebot.EventSource) string {
+	switch source.Type {
+	case linebot.EventSourceTypeGroup:
+		return source.GroupID
+	case linebot.EventSourceTypeRoom:
+		return source.RoomID
+	default:
+		return source.UserID
+	}
+}
+
+// chatSettings returns the settings of the chat. It returns false if they cannot be read.
+func (a *App) chatSettings(ctx context.Context, source *linebot.EventSource) (models.ChatSettings, bool) {
+	settings, err := a.settings.GetChatSettings(chatKey(source))
+	if err != nil {
+		a.log(ctx).Error("fetching the chat settings", "error", err)
+		return settings, false
+	}
+
+	return settings, true
+}
+
+// replyWithNamedMeme replies to the event (with the replyToken) with the meme of the exact
+// name followed by the messages. The meme is skipped if it does not exist.
+func (a *App) replyWithNamedMeme(ctx context.Context, replyToken string, source *linebot.EventSource, name string, messages ...linebot.SendingMessage) {
+	url, err := a.memeModel.Get(name)
+	if err == nil {
+		messages = append([]linebot.SendingMessage{linebot.NewImageMessage(url, url)}, messages...)
+	} else if !errors.Is(err, models.ErrNoRecord) {
+		a.log(ctx).Error("fetching the meme", "name", name, "error", err)
+	}
+
+	if len(messages) == 0 {
+		return
+	}
+
+	_, err = a.bot.ReplyMessage(replyToken, messages...).Do()
+	if err != nil {
+		a.log(ctx).Error("sending the reply message with the meme", "name", name, "error", err)
+		return
+	}
+
+	if url != "" {
+		sourceType, sourceID := a.sourceOf(source)
+		a.usages.record(models.Usage{
+			Link:       url,
+			Keyword:    name,
+			SourceType: sourceType,
+			SourceID:   sourceID,
+			Time:       time.Now(),
+		})
+	}
+}
+
+// memeSettingCommand returns the handler of a command which sets a meme of the chat with set,
+// e.g. "/greeting 我就爛.jpg". "reset" goes back to the global default.
+func (a *App) memeSettingCommand(usage string, set func(s *models.ChatSettings, name string)) commandHandler {
+	return func(ctx context.Context, event *linebot.Event, args string) []linebot.SendingMessage {
+		name := memeName(args)
+		if name == "" {
+			return textMessages(usage)
+		}
+
+		if name == memeSettingReset {
+			name = ""
+		} else if _, err := a.memeModel.Get(name); err != nil {
+			return textMessages(memeSettingNotFound + name + keywordSuffix)
+		}
+
+		chatID := chatKey(event.Source)
+		settings, err := a.settings.GetChatSettings(chatID)
+		if err != nil {
+			a.log(ctx).Error("fetching the chat settings", "error", err)
+			return nil
+		}
+
+		set(&settings, name)
+		if err = a.settings.SaveChatSettings(chatID, settings); err != nil {
+			a.log(ctx).Error("saving the chat settings", "error", err)
+			return nil
+		}
+
+		if name == "" {
+			return textMessages(memeSettingSetReply + "預設 default")
+		}
+
+		return textMessages(memeSettingSetReply + name + keywordSuffix)
+	}
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestMemeName(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName string
+		name     string
+		want     string
+	}{
+		{"Name", "bonjour", "bonjour"},
+		{"With suffix", "bonjour.jpg", "bonjour"},
+		{"Upper case", " Bonjour.PNG ", "bonjour"},
+		{"Empty", "", ""},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			got := memeName(tc.name)
+
+			// Want.
+			if got != tc.want {
+				t.Errorf("want %q; got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGreetingSettings(t *testing.T) {
+	// Stub.
+	a := newTestApp(t)
+	group := &linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: "group"}
+	other := &linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: "other"}
+	event := &linebot.Event{Source: group}
+
+	// Testcases.
+	tests := []struct {
+		testName     string
+		command      string
+		wantReply    string
+		wantGreeting string
+		wantFarewell string
+		salt         string // The statistics salt, changed before the command.
+	}{
+		{"Global defaults", "", "", "bonjour", "adios", ""},
+		{"Set greeting", "/greeting 我就爛.jpg", memeSettingSetReply + "我就爛.jpg", "我就爛", "adios", ""},
+		{"Set farewell without suffix", "/farewell 我就爛", memeSettingSetReply + "我就爛.jpg", "我就爛", "我就爛", ""},
+		{"Nonexistent meme", "/greeting xyz.jpg", memeSettingNotFound + "xyz.jpg", "我就爛", "我就爛", ""},
+		{"Usage", "/greeting", greetingUsage, "我就爛", "我就爛", ""},
+		{"Reset", "/greeting reset", memeSettingSetReply + "預設 default", "bonjour", "我就爛", ""},
+		{"Kept when the salt changes", "", "", "bonjour", "我就爛", "new salt"},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When.
+			a.statsHashSalt = tc.salt
+			if tc.command != "" {
+				messages, _ := a.commands.dispatch(context.Background(), event, tc.command)
+				if text := messages[0].(*linebot.TextMessage).Text; text != tc.wantReply {
+					t.Errorf("want reply %q; got %q", tc.wantReply, text)
+				}
+			}
+
+			// Want.
+			if got := a.greetingOf(context.Background(), group); got != tc.wantGreeting {
+				t.Errorf("want greeting %q; got %q", tc.wantGreeting, got)
+			}
+			if got := a.farewellOf(context.Background(), group); got != tc.wantFarewell {
+				t.Errorf("want farewell %q; got %q", tc.wantFarewell, got)
+			}
+
+			// Other chats are not affected.
+			if got := a.greetingOf(context.Background(), other); got != "bonjour" {
+				t.Errorf("want greeting of other chats %q; got %q", "bonjour", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// Policies when the event queue is full.
+const (
+	dropPolicy  = "drop"  // Drop the event.
+	blockPolicy = "block" // Wait for room until the webhook request is canceled.
+)
+
+// webhookJob is a webhook event waiting to be processed.
+type webhookJob struct {
+	event    *linebot.Event
+	delivery webhookDelivery // Empty if unknown.
+	logger   *slog.Logger    // The logger of the webhook request, if any.
+}
+
+// eventPoolStats are the counters of an event pool.
+type eventPoolStats struct {
+	Queued    uint64
+	Processed uint64
+	Dropped   uint64
+	Pending   int // The number of events in the queue.
+}
+
+// eventPool processes the webhook events with a bounded queue and a fixed number of workers,
+// so that the webhook can return before the database and LINE API calls are done.
+type eventPool struct {
+	queue  chan webhookJob
+	policy string
+	handle func(job webhookJob)
+	logger *slog.Logger
+	wg     sync.WaitGroup
+
+	// closed is guarded by mu, so that no job is queued after the queue is closed.
+	mu     sync.RWMutex
+	closed bool
+
+	queued    atomic.Uint64
+	processed atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// newEventPool returns an event pool with its workers started.
+func newEventPool(workers, queueSize int, policy string, handle func(job webhookJob), logger *slog.Logger) *eventPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &eventPool{
+		queue:  make(chan webhookJob, queueSize),
+		policy: policy,
+		handle: handle,
+		logger: logger,
+	}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for job := range p.queue {
+				p.handle(job)
+				p.processed.Add(1)
+			}
+		}()
+	}
+
+	return p
+}
+
+// submit queues the job. When the queue is full, the job is dropped, or with the block policy,
+// waits for room until ctx is done. It returns false if the job is dropped.
+func (p *eventPool) submit(ctx context.Context, job webhookJob) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.dropped.Add(1)
+		return false
+	}
+
+	select {
+	case p.queue <- job:
+		p.queued.Add(1)
+		return true
+	default:
+	}
+
+	if p.policy == blockPolicy {
+		select {
+		case p.queue <- job:
+			p.queued.Add(1)
+			return true
+		case <-ctx.Done():
+		}
+	}
+
+	p.dropped.Add(1)
+	p.logger.Warn("event queue is full, dropping the event", "event_type", job.event.Type)
+	return false
+}
+
+// stats returns the counters of the pool.
+func (p *eventPool) stats() eventPoolStats {
+	return eventPoolStats{
+		Queued:    p.queued.Load(),
+		Processed: p.processed.Load(),
+		Dropped:   p.dropped.Load(),
+		Pending:   len(p.queue),
+	}
+}
+
+// close stops accepting jobs and waits for the queued ones to be processed.
+func (p *eventPool) close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+
+	stats := p.stats()
+	p.logger.Info("event pool closed",
+		"queued", stats.Queued, "processed", stats.Processed, "dropped", stats.Dropped)
+}
+
+// processEvent routes the webhook event unless it is already processed. Every log line of the
+// event has its ID and source.
+func (a *App) processEvent(job webhookJob) {
+	logger := job.logger
+	if logger == nil {
+		logger = a.logger
+	}
+	ctx := withLogger(context.Background(), a.eventLogger(logger, job.event, job.delivery))
+
+	if a.isProcessed(ctx, job.delivery.WebhookEventID) {
+		a.log(ctx).Info("skipping processed webhook event")
+		return
+	}
+
+	a.events.route(ctx, job.event)
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestEventPool(t *testing.T) {
+	// Testcases.
+	tests := []struct {
+		testName      string
+		policy        string
+		wantProcessed uint64
+		wantDropped   uint64
+	}{
+		{"Drop", dropPolicy, 3, 2},
+		{"Block", blockPolicy, 5, 0},
+	}
+
+	// Perform tests.
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Stub. The worker is busy until released, with room for two more events.
+			release := make(chan struct{})
+			var handled atomic.Uint64
+			p := newEventPool(1, 2, tc.policy, func(job webhookJob) {
+				<-release
+				handled.Add(1)
+			}, slog.Default())
+			job := webhookJob{event: &linebot.Event{Type: linebot.EventTypeMessage}}
+
+			// When.
+			p.submit(context.Background(), job)
+			for p.stats().Pending > 0 {
+				// Wait for the worker to take the first event.
+				time.Sleep(time.Millisecond)
+			}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 4; i++ {
+					p.submit(context.Background(), job)
+				}
+			}()
+
+			if tc.policy == dropPolicy {
+				<-done
+			}
+			close(release)
+			<-done
+			p.close()
+
+			// Want.
+			stats := p.stats()
+			if stats.Processed != tc.wantProcessed || stats.Dropped != tc.wantDropped {
+				t.Errorf("want %d processed and %d dropped; got %+v", tc.wantProcessed, tc.wantDropped, stats)
+			}
+			if handled.Load() != stats.Processed {
+				t.Errorf("want %d handled; got %d", stats.Processed, handled.Load())
+			}
+		})
+	}
+}
+
+func TestEventPoolBlockCanceled(t *testing.T) {
+	// Stub. No room for any event.
+	release := make(chan struct{})
+	p := newEventPool(1, 0, blockPolicy, func(job webhookJob) { <-release }, slog.Default())
+	defer p.close()
+	defer close(release)
+	job := webhookJob{event: &linebot.Event{Type: linebot.EventTypeMessage}}
+
+	go p.submit(context.Background(), job)
+	for p.stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// When.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ok := p.submit(ctx, job)
+
+	// Want.
+	if ok || p.stats().Dropped != 1 {
+		t.Errorf("want the event dropped; got %+v", p.stats())
+	}
+}
+
+func TestEventPoolClosed(t *testing.T) {
+	// Stub.
+	p := newEventPool(2, 10, dropPolicy, func(job webhookJob) {}, slog.Default())
+	p.close()
+
+	// When.
+	ok := p.submit(context.Background(), webhookJob{event: &linebot.Event{}})
+
+	// Want.
+	if ok {
+		t.Error("want no events accepted after close")
+	}
+}
@@ -2,23 +2,45 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"time"
 )
 
 // Config contains a ServerConfig, LineBotConfig and a DBConfig for the configurations of the app.
 type Config struct {
-	AdminSecret string
-	Server      ServerConfig
-	LineBot     LineBotConfig
-	DB          DBConfig
+	Admin     AdminConfig
+	Server    ServerConfig
+	LineBot   LineBotConfig
+	DB        DBConfig
+	Stats     StatsConfig
+	Chat      ChatConfig
+	RateLimit RateLimitConfig
+	Webhook   WebhookConfig
+	Log       LogConfig
+	Trash     TrashConfig
+}
+
+// AdminConfig defines the authentication of the admin APIs. Secret is a key with all scopes,
+// named "admin", besides the API keys in the database. KeyPepper derives the signing secrets
+// of the keys, and signed requests are rejected without it. RequireSignature rejects the
+// unsigned requests, and SignatureMaxAge is how far the timestamp of a signed request may be off.
+// TrustProxy takes the client IP address from X-Forwarded-For, for a server behind a proxy.
+type AdminConfig struct {
+	Secret           string
+	KeyPepper        string
+	RequireSignature bool
+	SignatureMaxAge  time.Duration
+	TrustProxy       bool
 }
 
 // ServerConfig defines the configurations of the webserver.
+// ShutdownTimeout is how long to wait for the in-flight work when the app stops.
 type ServerConfig struct {
-	Port         string
-	IdleTimeout  time.Duration
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
+	Port            string
+	IdleTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	ShutdownTimeout time.Duration
 }
 
 // LineBotConfig defines the configurations of the line bot client.
@@ -28,9 +50,76 @@ type LineBotConfig struct {
 }
 
 // DBConfig defines the configurations of the DB connection.
+// Dialect is "postgres", "sqlite" or "memory" (an in-memory storage which needs no database).
+// The connection is retried with exponential backoff from RetryInitial up to RetryMax between
+// the attempts, and given up after ConnectMaxAttempts attempts or ConnectTimeout (0 for no
+// limit). MaxOpenConns (0 for no limit) and ConnMaxLifetime configure the connection pool.
 type DBConfig struct {
-	Dialect       string
-	ConnectionURL string
+	Dialect            string
+	ConnectionURL      string
+	RetryInitial       time.Duration
+	RetryMax           time.Duration
+	ConnectMaxAttempts int
+	ConnectTimeout     time.Duration
+	MaxOpenConns       int
+	ConnMaxLifetime    time.Duration
+}
+
+// StatsConfig defines the configurations of the meme usage statistics.
+// HashSalt is the secret used to hash the LINE user, group and room IDs in the statistics.
+// Changing it starts the statistics of each chat afresh; the chat settings are not affected.
+type StatsConfig struct {
+	HashSalt string
+}
+
+// ChatConfig defines the global defaults of the chat settings, which each chat can override.
+// GreetingMeme and FarewellMeme are the meme names sent when members join or leave a chat.
+// MemeCooldown is how long before the same meme can be sent again in a group or room.
+type ChatConfig struct {
+	GreetingMeme string
+	FarewellMeme string
+	MemeCooldown time.Duration
+}
+
+// RateLimitConfig defines the limits of the meme replies per user and per group or room.
+// A rate is the average number of replies per minute, 0 for no limits, and a burst is the
+// number of replies allowed at once. SlowDownReply tells the bot to reply "slow down" once
+// when a limit is hit.
+type RateLimitConfig struct {
+	UserPerMinute  float64
+	UserBurst      int
+	GroupPerMinute float64
+	GroupBurst     int
+	SlowDownReply  bool
+}
+
+// WebhookConfig defines how the webhook events are processed. Workers process the events in
+// a queue of QueueSize; when it is full, QueuePolicy "drop" drops the event and "block" waits
+// for room. DedupStore is "memory" for a cache of DedupSize events in each instance, or
+// "database" to share the processed events among the instances, so that the redelivered
+// events are skipped. DedupTTL is how long an event is remembered.
+type WebhookConfig struct {
+	Workers     int
+	QueueSize   int
+	QueuePolicy string
+	DedupStore  string
+	DedupTTL    time.Duration
+	DedupSize   int
+}
+
+// TrashConfig defines the trash of the deleted memes. Retention is how long a deleted meme is
+// kept before it can be purged.
+type TrashConfig struct {
+	Retention time.Duration
+}
+
+// LogConfig defines the logging. Level is "debug", "info", "warn" or "error", and Format is
+// "json" or "text" (logfmt). HashSourceIDs hashes the LINE user, group and room IDs in the logs
+// like the statistics do.
+type LogConfig struct {
+	Level         string
+	Format        string
+	HashSourceIDs bool
 }
 
 var conf Config
@@ -38,20 +127,65 @@ var conf Config
 // Initialize the config struct from the environment variables.
 func init() {
 	conf = Config{
-		AdminSecret: os.Getenv("ADMIN_SECRET"),
+		Admin: AdminConfig{
+			Secret:           os.Getenv("ADMIN_SECRET"),
+			KeyPepper:        os.Getenv("ADMIN_KEY_PEPPER"),
+			RequireSignature: getEnvBool("ADMIN_REQUIRE_SIGNATURE", false),
+			SignatureMaxAge:  time.Duration(getEnvInt("ADMIN_SIGNATURE_MAX_AGE_SECONDS", 300)) * time.Second,
+			TrustProxy:       getEnvBool("ADMIN_TRUST_PROXY", false),
+		},
 		Server: ServerConfig{
 			Port:         ":" + os.Getenv("PORT"),
 			IdleTimeout:  time.Minute,
 			ReadTimeout:  5 * time.Second,
 			WriteTimeout: 10 * time.Second,
+			// Heroku and Docker kill the app 30 and 10 seconds after SIGTERM by default.
+			ShutdownTimeout: time.Duration(getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 8)) * time.Second,
 		},
 		LineBot: LineBotConfig{
 			ChannelSecret:      os.Getenv("LINE_CHANNEL_SECRET"),
 			ChannelAccessToken: os.Getenv("LINE_CHANNEL_ACCESS_TOKEN"),
 		},
 		DB: DBConfig{
-			Dialect:       "postgres",
-			ConnectionURL: os.Getenv("DATABASE_URL"),
+			Dialect:            getEnv("DATABASE_DIALECT", "postgres"),
+			ConnectionURL:      os.Getenv("DATABASE_URL"),
+			RetryInitial:       time.Duration(getEnvInt("DATABASE_RETRY_INITIAL_MS", 500)) * time.Millisecond,
+			RetryMax:           time.Duration(getEnvInt("DATABASE_RETRY_MAX_SECONDS", 30)) * time.Second,
+			ConnectMaxAttempts: getEnvInt("DATABASE_CONNECT_MAX_ATTEMPTS", 0),
+			ConnectTimeout:     time.Duration(getEnvInt("DATABASE_CONNECT_TIMEOUT_SECONDS", 300)) * time.Second,
+			MaxOpenConns:       getEnvInt("DATABASE_MAX_OPEN_CONNS", 10),
+			ConnMaxLifetime:    time.Duration(getEnvInt("DATABASE_CONN_MAX_LIFETIME_MINUTES", 30)) * time.Minute,
+		},
+		Stats: StatsConfig{
+			HashSalt: os.Getenv("STATS_HASH_SALT"),
+		},
+		Chat: ChatConfig{
+			GreetingMeme: getEnv("GREETING_MEME", "bonjour"),
+			FarewellMeme: getEnv("FAREWELL_MEME", "adios"),
+			MemeCooldown: time.Duration(getEnvInt("MEME_COOLDOWN_SECONDS", 10)) * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			UserPerMinute:  getEnvFloat("RATE_LIMIT_USER_PER_MINUTE", 10),
+			UserBurst:      getEnvInt("RATE_LIMIT_USER_BURST", 5),
+			GroupPerMinute: getEnvFloat("RATE_LIMIT_GROUP_PER_MINUTE", 30),
+			GroupBurst:     getEnvInt("RATE_LIMIT_GROUP_BURST", 10),
+			SlowDownReply:  getEnvBool("RATE_LIMIT_SLOW_DOWN_REPLY", true),
+		},
+		Webhook: WebhookConfig{
+			Workers:     getEnvInt("WEBHOOK_WORKERS", 4),
+			QueueSize:   getEnvInt("WEBHOOK_QUEUE_SIZE", 256),
+			QueuePolicy: getEnv("WEBHOOK_QUEUE_POLICY", "drop"),
+			DedupStore:  getEnv("WEBHOOK_DEDUP_STORE", "memory"),
+			DedupTTL:    time.Duration(getEnvInt("WEBHOOK_DEDUP_TTL_MINUTES", 60)) * time.Minute,
+			DedupSize:   getEnvInt("WEBHOOK_DEDUP_SIZE", 10000),
+		},
+		Log: LogConfig{
+			Level:         getEnv("LOG_LEVEL", "info"),
+			Format:        getEnv("LOG_FORMAT", "json"),
+			HashSourceIDs: getEnvBool("LOG_HASH_SOURCE_IDS", true),
+		},
+		Trash: TrashConfig{
+			Retention: time.Duration(getEnvInt("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour,
 		},
 	}
 }
@@ -60,3 +194,38 @@ func init() {
 func GetConfig() *Config {
 	return &conf
 }
+
+// getEnv returns the environment variable named key, or fallback if it is not set.
+func getEnv(key, fallback string) string {
+	if val, ok := os.LookupEnv(key); ok {
+		return val
+	}
+	return fallback
+}
+
+// getEnvInt returns the environment variable named key as an int, or fallback if it is not set
+// or invalid.
+func getEnvInt(key string, fallback int) int {
+	if val, err := strconv.Atoi(getEnv(key, "")); err == nil {
+		return val
+	}
+	return fallback
+}
+
+// getEnvFloat returns the environment variable named key as a float64, or fallback if it is
+// not set or invalid.
+func getEnvFloat(key string, fallback float64) float64 {
+	if val, err := strconv.ParseFloat(getEnv(key, ""), 64); err == nil {
+		return val
+	}
+	return fallback
+}
+
+// getEnvBool returns the environment variable named key as a bool, or fallback if it is not
+// set or invalid.
+func getEnvBool(key string, fallback bool) bool {
+	if val, err := strconv.ParseBool(getEnv(key, "")); err == nil {
+		return val
+	}
+	return fallback
+}
@@ -0,0 +1,355 @@
+// Package database embeds the versioned schema migrations of the meme storage and applies
+// them to a database.
+//
+// Migrations live in migrations/<dialect>/ and are named <version>_<name>.up.sql with an
+// optional <version>_<name>.down.sql. Applied migrations are recorded in the schema_version
+// table along with the checksum of their up script, so that an applied migration which was
+// modified afterwards is detected instead of silently diverging.
+//
+// Migrators sharing a database, e.g. the instances of the app starting at once, are serialized
+// by a lock, so that each migration is applied once.
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationLockID is the key of the Postgres advisory lock held while migrating.
+const migrationLockID = 4829175301
+
+// ErrChecksumMismatch is returned when an applied migration differs from the embedded one.
+var ErrChecksumMismatch = errors.New("database: checksum mismatch of an applied migration")
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus tells whether a migration has been applied and when.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the migrations of a dialect to a database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// querier runs the statements of the migrator, on the database or on a single connection.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// NewMigrator returns a migrator for the database with the embedded migrations of the dialect.
+func NewMigrator(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// loadMigrations reads the embedded migrations of the dialect, ordered by version.
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("database: no migrations for dialect %q", dialect)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		matches := migrationFileName.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("database: invalid migration file name %q", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(matches[1])
+		script, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		} else if m.Name != matches[2] {
+			return nil, fmt.Errorf("database: conflicting names for migration %d", version)
+		}
+
+		if matches[3] == "up" {
+			m.Up = string(script)
+			sum := sha256.Sum256(script)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	res := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("database: migration %d has no up script", m.Version)
+		}
+		res = append(res, *m)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+
+	return res, nil
+}
+
+// ensureVersionTable creates the schema_version table if it does not exist.
+func (m *Migrator) ensureVersionTable(ctx context.Context, q querier) error {
+	stmt := `CREATE TABLE IF NOT EXISTS schema_version(
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(128) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+	_, err := q.ExecContext(ctx, stmt)
+	return err
+}
+
+// applied returns the applied migrations keyed by version, and validates their checksums.
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int]MigrationStatus, error) {
+	if err := m.ensureVersionTable(ctx, q); err != nil {
+		return nil, err
+	}
+
+	stmt := `SELECT version, name, checksum, applied_at FROM schema_version`
+	rows, err := q.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checksums := map[int]string{}
+	for _, migration := range m.migrations {
+		checksums[migration.Version] = migration.Checksum
+	}
+
+	res := map[int]MigrationStatus{}
+	for rows.Next() {
+		var s MigrationStatus
+		var checksum string
+		err = rows.Scan(&s.Version, &s.Name, &checksum, &s.AppliedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		want, ok := checksums[s.Version]
+		if !ok {
+			return nil, fmt.Errorf("database: applied migration %d is unknown to this build", s.Version)
+		}
+		if want != checksum {
+			return nil, fmt.Errorf("%w: version %d", ErrChecksumMismatch, s.Version)
+		}
+
+		s.Applied = true
+		res[s.Version] = s
+	}
+
+	return res, rows.Err()
+}
+
+// Status returns the status of every known migration, ordered by version.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied(context.Background(), m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []MigrationStatus{}
+	for _, migration := range m.migrations {
+		s, ok := applied[migration.Version]
+		if !ok {
+			s = MigrationStatus{Version: migration.Version, Name: migration.Name}
+		}
+		res = append(res, s)
+	}
+
+	return res, nil
+}
+
+// Up applies all pending migrations in order and returns the number of applied migrations.
+// Each migration is applied atomically.
+func (m *Migrator) Up() (int, error) {
+	count := 0
+	err := m.locked(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			stmt := `INSERT INTO schema_version (version, name, checksum) VALUES ($1, $2, $3)`
+			err = m.step(ctx, conn, migration.Up, stmt, migration.Version, migration.Name, migration.Checksum)
+			if err != nil {
+				return fmt.Errorf("database: applying migration %d: %w", migration.Version, err)
+			}
+
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Down rolls back the latest steps applied migrations and returns the number of rolled back
+// migrations.
+func (m *Migrator) Down(steps int) (int, error) {
+	count := 0
+	err := m.locked(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("database: migration %d has no down script", migration.Version)
+			}
+
+			stmt := `DELETE FROM schema_version WHERE version = $1`
+			err = m.step(ctx, conn, migration.Down, stmt, migration.Version)
+			if err != nil {
+				return fmt.Errorf("database: rolling back migration %d: %w", migration.Version, err)
+			}
+
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Reset rolls back all applied migrations and returns the number of rolled back migrations.
+func (m *Migrator) Reset() (int, error) {
+	return m.Down(len(m.migrations))
+}
+
+// locked runs f on a single connection while holding the migration lock. On Postgres, it is a
+// session advisory lock. SQLite has none, so f runs in a transaction started with BEGIN
+// IMMEDIATE, which holds the write lock of the database until it commits.
+func (m *Migrator) locked(f func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect != "sqlite" {
+		if _, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+			return fmt.Errorf("database: taking the migration lock: %w", err)
+		}
+		defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+		return f(ctx, conn)
+	}
+
+	if _, err = conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return fmt.Errorf("database: taking the migration lock: %w", err)
+	}
+
+	// A failed step is already rolled back to its savepoint, so the previous steps are kept.
+	err = f(ctx, conn)
+	if _, commitErr := conn.ExecContext(ctx, `COMMIT`); commitErr != nil {
+		conn.ExecContext(ctx, `ROLLBACK`)
+		return errors.Join(err, commitErr)
+	}
+
+	return err
+}
+
+// step executes the migration script and the schema_version statement atomically, in a
+// transaction, or in a savepoint of the transaction holding the SQLite lock.
+func (m *Migrator) step(ctx context.Context, conn *sql.Conn, script string, stmt string, args ...interface{}) error {
+	if m.dialect == "sqlite" {
+		return m.inSavepoint(ctx, conn, script, stmt, args...)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, stmt, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// inSavepoint executes the migration script and the schema_version statement in a savepoint,
+// which is rolled back if either fails.
+func (m *Migrator) inSavepoint(ctx context.Context, conn *sql.Conn, script string, stmt string, args ...interface{}) error {
+	if _, err := conn.ExecContext(ctx, `SAVEPOINT migration`); err != nil {
+		return err
+	}
+
+	_, err := conn.ExecContext(ctx, script)
+	if err == nil {
+		_, err = conn.ExecContext(ctx, stmt, args...)
+	}
+	if err != nil {
+		conn.ExecContext(ctx, `ROLLBACK TO migration`)
+	}
+
+	if _, releaseErr := conn.ExecContext(ctx, `RELEASE migration`); releaseErr != nil {
+		return errors.Join(err, releaseErr)
+	}
+
+	return err
+}
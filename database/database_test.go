@@ -0,0 +1,218 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// newTestMigrator returns a migrator on an empty in-memory SQLite database along with its
+// teardown function.
+func newTestMigrator(t *testing.T) (*Migrator, func()) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+
+	m, err := NewMigrator(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m, func() {
+		db.Close()
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	for _, dialect := range []string{"postgres", "sqlite"} {
+		t.Run(dialect, func(t *testing.T) {
+			// When.
+			migrations, err := loadMigrations(dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Want.
+			for i, m := range migrations {
+				if m.Version != i+1 {
+					t.Errorf("want version %v; got %v", i+1, m.Version)
+				}
+				if m.Down == "" {
+					t.Errorf("want a down script for version %v", m.Version)
+				}
+			}
+		})
+	}
+
+	if _, err := loadMigrations("memory"); err == nil {
+		t.Errorf("want error for a dialect without migrations; got nil")
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	// Stub.
+	m, teardown := newTestMigrator(t)
+	defer teardown()
+
+	// When.
+	count, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Want.
+	if count != len(m.migrations) {
+		t.Errorf("want %v; got %v", len(m.migrations), count)
+	}
+
+	if count, err = m.Up(); err != nil || count != 0 {
+		t.Errorf("want 0 migrations applied twice; got %v (err: %v)", count, err)
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if !s.Applied {
+			t.Errorf("want migration %v applied", s.Version)
+		}
+	}
+
+	// When.
+	count, err = m.Down(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Want.
+	if count != 1 {
+		t.Errorf("want %v; got %v", 1, count)
+	}
+
+	statuses, err = m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := statuses[len(statuses)-1]; last.Applied {
+		t.Errorf("want migration %v rolled back", last.Version)
+	}
+
+	if _, err = m.Reset(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentUp(t *testing.T) {
+	// Stub. Several instances share a database file.
+	url := "file:" + filepath.Join(t.TempDir(), "memes.db") + "?_pragma=busy_timeout(5000)"
+	migrators := []*Migrator{}
+	for i := 0; i < 4; i++ {
+		db, err := sql.Open("sqlite", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		m, err := NewMigrator(db, "sqlite")
+		if err != nil {
+			t.Fatal(err)
+		}
+		migrators = append(migrators, m)
+	}
+
+	// When.
+	var wg sync.WaitGroup
+	counts, errs := make([]int, len(migrators)), make([]error, len(migrators))
+	for i, m := range migrators {
+		wg.Add(1)
+		go func(i int, m *Migrator) {
+			defer wg.Done()
+			counts[i], errs[i] = m.Up()
+		}(i, m)
+	}
+	wg.Wait()
+
+	// Want. Each migration is applied once.
+	total := 0
+	for i := range migrators {
+		if errs[i] != nil {
+			t.Errorf("migrator %d: %v", i, errs[i])
+		}
+		total += counts[i]
+	}
+	if total != len(migrators[0].migrations) {
+		t.Errorf("want %v migrations applied; got %v", len(migrators[0].migrations), total)
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	// Stub.
+	m, teardown := newTestMigrator(t)
+	defer teardown()
+
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	// When.
+	if _, err := m.db.Exec(`UPDATE schema_version SET checksum = 'tampered' WHERE version = 1`); err != nil {
+		t.Fatal(err)
+	}
+	_, err := m.Up()
+
+	// Want.
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("want %v; got %v", ErrChecksumMismatch, err)
+	}
+}
+
+func TestNormalizeMemes(t *testing.T) {
+	// Stub with the denormalized schema.
+	m, teardown := newTestMigrator(t)
+	defer teardown()
+
+	all := m.migrations
+	m.migrations = all[:1]
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := m.db.Exec(`INSERT INTO memes (name, url) VALUES
+		('honest work', 'BPCZHUi.png'), ('我就爛', 't9WaxTw.png'), ('it ain''t much', 'BPCZHUi.png')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When.
+	m.migrations = all[:2]
+	if _, err = m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Want.
+	var memes, aliases, sharing int
+	m.db.QueryRow(`SELECT COUNT(*) FROM memes`).Scan(&memes)
+	m.db.QueryRow(`SELECT COUNT(*) FROM aliases`).Scan(&aliases)
+	m.db.QueryRow(`SELECT COUNT(*) FROM aliases a JOIN memes m ON m.id = a.meme_id WHERE m.url = 'BPCZHUi.png'`).Scan(&sharing)
+	if memes != 2 || aliases != 3 || sharing != 2 {
+		t.Errorf("want 2 memes, 3 aliases, 2 sharing; got %v, %v, %v", memes, aliases, sharing)
+	}
+
+	// When.
+	if _, err = m.Down(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Want.
+	m.db.QueryRow(`SELECT COUNT(*) FROM memes WHERE url = 'BPCZHUi.png'`).Scan(&sharing)
+	if sharing != 2 {
+		t.Errorf("want %v; got %v", 2, sharing)
+	}
+}
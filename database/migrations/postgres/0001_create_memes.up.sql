@@ -6,7 +6,8 @@
 -- Note that there can be alias names for the same meme (i.e. memes with
 -- the same URL), that's why it's denormalized.
 
-CREATE TABLE memes(
+-- IF NOT EXISTS so that databases set up by the old setup.sql can adopt the migrations.
+CREATE TABLE IF NOT EXISTS memes(
     id SERIAL PRIMARY KEY,
     name VARCHAR(128) UNIQUE,
     url VARCHAR(128)
@@ -15,4 +16,4 @@ CREATE TABLE memes(
 -- For SIMILARITY function.
 -- Used for fuzzy keyword search.
 CREATE EXTENSION IF NOT EXISTS fuzzystrmatch;
-CREATE EXTENSION IF NOT EXISTS pg_trgm;
\ No newline at end of file
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
@@ -0,0 +1,20 @@
+-- Normalize the denormalized memes table: memes holds one row per image and
+-- aliases holds the keywords, several of which may refer to the same image.
+
+CREATE TABLE aliases(
+    id SERIAL PRIMARY KEY,
+    meme_id INTEGER NOT NULL REFERENCES memes(id) ON DELETE CASCADE,
+    name VARCHAR(128) NOT NULL UNIQUE
+);
+
+-- Every name becomes an alias of the first meme with the same URL.
+INSERT INTO aliases (meme_id, name)
+    SELECT (SELECT MIN(m2.id) FROM memes m2 WHERE m2.url = m.url), m.name
+    FROM memes m WHERE m.name IS NOT NULL;
+
+DELETE FROM memes WHERE id NOT IN (SELECT MIN(id) FROM memes GROUP BY url);
+
+ALTER TABLE memes DROP COLUMN name;
+ALTER TABLE memes ADD CONSTRAINT memes_url_key UNIQUE (url);
+
+CREATE INDEX aliases_meme_id_idx ON aliases (meme_id);
@@ -0,0 +1,16 @@
+-- Every meme sent by the bot. The source ID is hashed by the app so that the
+-- statistics cannot be traced back to a LINE user, group or room.
+
+CREATE TABLE usages(
+    id SERIAL PRIMARY KEY,
+    meme_id INTEGER NOT NULL REFERENCES memes(id) ON DELETE CASCADE,
+    keyword VARCHAR(128) NOT NULL,
+    fuzzy BOOLEAN NOT NULL,
+    source_type VARCHAR(8) NOT NULL,
+    source_id VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX usages_created_at_idx ON usages (created_at);
+CREATE INDEX usages_source_id_idx ON usages (source_id, created_at);
+CREATE INDEX usages_meme_id_idx ON usages (meme_id);
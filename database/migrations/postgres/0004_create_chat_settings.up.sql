@@ -0,0 +1,7 @@
+-- Per-chat settings of a LINE user, group or room, keyed by the hashed source ID.
+-- A chat without a row uses the default settings.
+
+CREATE TABLE chat_settings(
+    source_id VARCHAR(64) PRIMARY KEY,
+    implicit_trigger BOOLEAN NOT NULL DEFAULT FALSE
+);
@@ -0,0 +1,3 @@
+-- Quick reply suggestions on fuzzy matches are on by default, so the column stores the opt-out.
+
+ALTER TABLE chat_settings ADD COLUMN no_quick_replies BOOLEAN NOT NULL DEFAULT FALSE;
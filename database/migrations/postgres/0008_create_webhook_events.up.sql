@@ -0,0 +1,8 @@
+-- Processed LINE webhook events, to skip the redelivered ones across app instances.
+
+CREATE TABLE webhook_events(
+    event_id VARCHAR(64) PRIMARY KEY,
+    processed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX webhook_events_processed_at_idx ON webhook_events (processed_at);
@@ -0,0 +1,10 @@
+-- Named API keys of the admin APIs. Only the SHA-256 hash of a key is stored, and
+-- the scopes are separated by spaces.
+
+CREATE TABLE api_keys(
+    name VARCHAR(64) PRIMARY KEY,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    scopes VARCHAR(256) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
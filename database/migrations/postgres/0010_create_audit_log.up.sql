@@ -0,0 +1,16 @@
+-- Every change made through the admin APIs. The old and new values are JSON snapshots of the
+-- meme, so that a deleted meme can be told apart and restored.
+
+CREATE TABLE audit_log(
+    id SERIAL PRIMARY KEY,
+    actor VARCHAR(64) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    meme_name VARCHAR(128) NOT NULL,
+    old_value TEXT NOT NULL,
+    new_value TEXT NOT NULL,
+    client_ip VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX audit_log_meme_name_idx ON audit_log (meme_name);
@@ -0,0 +1,4 @@
+-- The memes in the trash are restored.
+
+DROP INDEX memes_deleted_at_idx;
+ALTER TABLE memes DROP COLUMN deleted_at;
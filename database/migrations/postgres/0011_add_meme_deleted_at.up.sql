@@ -0,0 +1,6 @@
+-- Deleted memes are kept in the trash until purged. A meme is in the trash if deleted_at is
+-- set, and its aliases stay reserved so that it can be restored.
+
+ALTER TABLE memes ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX memes_deleted_at_idx ON memes (deleted_at);
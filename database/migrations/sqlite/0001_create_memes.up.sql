@@ -0,0 +1,10 @@
+-- SQLite version of the memes table. See the PostgreSQL migration for the design notes.
+
+-- The SIMILARITY function used for fuzzy keyword search is registered
+-- by the Go code (see app/models/sqlite.go) instead of an extension.
+
+CREATE TABLE IF NOT EXISTS memes(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(128) UNIQUE,
+    url VARCHAR(128)
+);
@@ -0,0 +1,14 @@
+-- Denormalize back to one row per name.
+
+CREATE TABLE memes_denormalized(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(128) UNIQUE,
+    url VARCHAR(128)
+);
+
+INSERT INTO memes_denormalized (name, url)
+    SELECT a.name, m.url FROM aliases a JOIN memes m ON m.id = a.meme_id ORDER BY a.id;
+
+DROP TABLE aliases;
+DROP TABLE memes;
+ALTER TABLE memes_denormalized RENAME TO memes;
@@ -0,0 +1,28 @@
+-- Normalize the denormalized memes table: memes holds one row per image and
+-- aliases holds the keywords, several of which may refer to the same image.
+-- SQLite cannot drop a UNIQUE column, so the memes table is rebuilt.
+
+CREATE TABLE memes_normalized(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url VARCHAR(128) UNIQUE
+);
+
+INSERT INTO memes_normalized (id, url)
+    SELECT MIN(id), url FROM memes GROUP BY url;
+
+-- The reference follows memes_normalized when it is renamed to memes.
+CREATE TABLE aliases(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    meme_id INTEGER NOT NULL REFERENCES memes_normalized(id) ON DELETE CASCADE,
+    name VARCHAR(128) NOT NULL UNIQUE
+);
+
+-- Every name becomes an alias of the first meme with the same URL.
+INSERT INTO aliases (meme_id, name)
+    SELECT (SELECT MIN(m2.id) FROM memes m2 WHERE m2.url = m.url), m.name
+    FROM memes m WHERE m.name IS NOT NULL;
+
+DROP TABLE memes;
+ALTER TABLE memes_normalized RENAME TO memes;
+
+CREATE INDEX aliases_meme_id_idx ON aliases (meme_id);
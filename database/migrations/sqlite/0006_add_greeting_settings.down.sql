@@ -0,0 +1,2 @@
+ALTER TABLE chat_settings DROP COLUMN farewell_meme;
+ALTER TABLE chat_settings DROP COLUMN greeting_meme;
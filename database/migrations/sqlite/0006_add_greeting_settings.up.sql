@@ -0,0 +1,4 @@
+-- Per-chat greeting and farewell meme names. An empty name uses the global default.
+
+ALTER TABLE chat_settings ADD COLUMN greeting_meme VARCHAR(128) NOT NULL DEFAULT '';
+ALTER TABLE chat_settings ADD COLUMN farewell_meme VARCHAR(128) NOT NULL DEFAULT '';
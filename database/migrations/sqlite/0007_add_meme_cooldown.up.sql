@@ -0,0 +1,4 @@
+-- Per-chat cooldown in seconds before the same meme can be sent again.
+-- 0 uses the global default, and a negative value turns the cooldown off.
+
+ALTER TABLE chat_settings ADD COLUMN meme_cooldown INTEGER NOT NULL DEFAULT 0;
@@ -1,5 +1,9 @@
-INSERT INTO memes (name, url) VALUES ('我就爛', 't9WaxTw.png');
-INSERT INTO memes (name, url) VALUES ('adios', '6UegMI2.png');
-INSERT INTO memes (name, url) VALUES ('bonjour', 'qg8sB6f.png');
-INSERT INTO memes (name, url) VALUES ('honest work', 'BPCZHUi.png');
-INSERT INTO memes (name, url) VALUES ('it ain''t much, but it''s honest work', 'BPCZHUi.png');
+INSERT INTO memes (url) VALUES ('t9WaxTw.png');
+INSERT INTO memes (url) VALUES ('6UegMI2.png');
+INSERT INTO memes (url) VALUES ('qg8sB6f.png');
+INSERT INTO memes (url) VALUES ('BPCZHUi.png');
+INSERT INTO aliases (meme_id, name) SELECT id, '我就爛' FROM memes WHERE url = 't9WaxTw.png';
+INSERT INTO aliases (meme_id, name) SELECT id, 'adios' FROM memes WHERE url = '6UegMI2.png';
+INSERT INTO aliases (meme_id, name) SELECT id, 'bonjour' FROM memes WHERE url = 'qg8sB6f.png';
+INSERT INTO aliases (meme_id, name) SELECT id, 'honest work' FROM memes WHERE url = 'BPCZHUi.png';
+INSERT INTO aliases (meme_id, name) SELECT id, 'it ain''t much, but it''s honest work' FROM memes WHERE url = 'BPCZHUi.png';
@@ -1,12 +1,49 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/YuChaoGithub/meme-linebot/app"
 	"github.com/YuChaoGithub/meme-linebot/config"
 )
 
 func main() {
 	c := config.GetConfig()
-	app := &app.App{}
-	app.InitializeAndRun(c)
+
+	// Subcommand for the database schema migrations.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := app.Migrate(c, os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Subcommand for the API keys of the admin APIs.
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		if err := app.APIKeys(c, os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	a, err := app.New(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Stop gracefully on Ctrl-C, or when Heroku or Docker stops the app.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err = a.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("The app is stopped.")
 }
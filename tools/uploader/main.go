@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -22,7 +25,9 @@ const (
 
 var validSuffixes = []string{".jpg", ".jpeg", ".png", ".gif"}
 var imgurClientID string
-var adminSecret string
+var adminAPIKey string
+var adminKeyName string
+var adminSigningSecret string
 
 func main() {
 	counter := 0
@@ -34,7 +39,9 @@ func main() {
 
 	// Get secret environment variables.
 	imgurClientID = os.Getenv("IMGUR_CLIENT_ID")
-	adminSecret = os.Getenv("ADMIN_SECRET")
+	adminAPIKey = os.Getenv("ADMIN_API_KEY")
+	adminKeyName = os.Getenv("ADMIN_KEY_NAME")
+	adminSigningSecret = os.Getenv("ADMIN_SIGNING_SECRET")
 
 	// Get the files from the directory.
 	dirPath := os.Args[1]
@@ -156,11 +163,9 @@ func uploadToImgur(filename string) (string, error) {
 
 func uploadToMemeDatabase(name, url string) error {
 	reqStruct := struct {
-		Admin string `json:"admin"`
-		Name  string `json:"name"`
-		Link  string `json:"link"`
+		Name string `json:"name"`
+		Link string `json:"link"`
 	}{
-		adminSecret,
 		name,
 		url,
 	}
@@ -176,12 +181,35 @@ func uploadToMemeDatabase(name, url string) error {
 		return err
 	}
 	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization(req, body))
 
 	// Send request.
-	_, err = http.DefaultClient.Do(req)
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("status %v - %s", res.StatusCode, bytes.TrimSpace(msg))
+	}
 
 	return nil
 }
+
+// authorization returns the Authorization header of the request. The request is signed with
+// the signing secret if the name of the API key is given, so that the key itself is never sent.
+func authorization(req *http.Request, body []byte) string {
+	if adminKeyName == "" {
+		return "Bearer " + adminAPIKey
+	}
+
+	bodySum := sha256.Sum256(body)
+	timestamp := time.Now().Unix()
+
+	mac := hmac.New(sha256.New, []byte(adminSigningSecret))
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%s", req.Method, req.URL.Path, timestamp, hex.EncodeToString(bodySum[:]))
+
+	return fmt.Sprintf("HMAC-SHA256 key=%s, ts=%d, sig=%s", adminKeyName, timestamp, hex.EncodeToString(mac.Sum(nil)))
+}